@@ -0,0 +1,87 @@
+package viammodbus
+
+import (
+	"fmt"
+
+	"github.com/simonvetter/modbus"
+)
+
+// Addressing modes for block offsets
+const (
+	AddressingZeroBased = "zero_based"
+	AddressingOneBased  = "one_based"
+	AddressingModicon   = "modicon"
+)
+
+func validateAddressing(addressing string) error {
+	switch addressing {
+	case "", AddressingZeroBased, AddressingOneBased, AddressingModicon:
+		return nil
+	default:
+		return fmt.Errorf("addressing must be one of %v, %v or %v, got %q", AddressingZeroBased, AddressingOneBased, AddressingModicon, addressing)
+	}
+}
+
+// Converts the block offset to a zero-based offset. With modicon addressing the register
+// table is taken from the leading digit and has to match the block type.
+func toZeroBased(addressing string, block ModbusBlocks) (ModbusBlocks, error) {
+	switch addressing {
+	case "", AddressingZeroBased:
+		return block, nil
+	case AddressingOneBased:
+		if block.Offset < 1 {
+			return block, fmt.Errorf("offset must be at least 1 with one_based addressing in block (%s), got %d", block.Name, block.Offset)
+		}
+		block.Offset--
+		return block, nil
+	}
+
+	table, number := block.Offset/10000, block.Offset%10000
+	if block.Offset >= 100000 {
+		// 6-digit addresses e.g. 400001
+		table, number = block.Offset/100000, block.Offset%100000
+	}
+	if number < 1 || number > 65536 {
+		return block, fmt.Errorf("invalid modicon address %d in block (%s)", block.Offset, block.Name)
+	}
+
+	var tableName, register string
+	switch table {
+	case 0:
+		tableName = "coils"
+	case 1:
+		tableName = "discrete_inputs"
+	case 3:
+		tableName, register = "input_registers", "input"
+	case 4:
+		tableName, register = "holding_registers", "holding"
+	default:
+		return block, fmt.Errorf("modicon address %d in block (%s) does not refer to a known table", block.Offset, block.Name)
+	}
+
+	switch {
+	case block.Type == "":
+		// Field override of a profile block, type is inherited
+	case block.Type == "coils" || block.Type == "discrete_inputs" || block.Type == "holding_registers" || block.Type == "input_registers":
+		if block.Type != tableName {
+			return block, fmt.Errorf("modicon address %d in block (%s) refers to %s but type is %s", block.Offset, block.Name, tableName, block.Type)
+		}
+	case register == "":
+		return block, fmt.Errorf("modicon address %d in block (%s) refers to %s, %s requires a register address", block.Offset, block.Name, tableName, block.Type)
+	case block.Register != "" && block.Register != register:
+		return block, fmt.Errorf("modicon address %d in block (%s) refers to %s but register is %s", block.Offset, block.Name, tableName, block.Register)
+	default:
+		block.Register = register
+	}
+
+	block.Offset = number - 1
+	return block, nil
+}
+
+// Returns the register table a typed block reads from
+func blockRegType(block ModbusBlocks) modbus.RegType {
+	if block.Register == "input" {
+		return modbus.INPUT_REGISTER
+	}
+	return modbus.HOLDING_REGISTER
+}
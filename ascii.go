@@ -0,0 +1,109 @@
+package viammodbus
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/simonvetter/modbus"
+)
+
+// The Modbus library has no ASCII framing, ascii:// urls always go through the bridge
+const asciiScheme = "ascii"
+
+// ASCII devices wait up to a second between the characters of a frame
+const defaultASCIITimeout = time.Second
+
+func isASCIIURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && u.Scheme == asciiScheme
+}
+
+// Modbus ASCII framing over a serial line: a colon, the unit id, PDU and LRC in hex and CR LF
+type asciiTransport struct {
+	linkHolder
+	timeout time.Duration
+	echo    bool
+}
+
+func (t *asciiTransport) exchange(unitID uint8, req []byte) ([]byte, error) {
+	link, err := t.get()
+	if err != nil {
+		return nil, err
+	}
+
+	frame := append([]byte{unitID}, req...)
+	frame = append(frame, lrc(frame))
+	link.SetDeadline(time.Now().Add(t.timeout))
+	if _, err := io.WriteString(link, ":"+strings.ToUpper(hex.EncodeToString(frame))+"\r\n"); err != nil {
+		return nil, t.fail(err)
+	}
+	if t.echo {
+		echo, err := readASCIIFrame(link)
+		if err != nil {
+			return nil, t.fail(err)
+		}
+		if !bytes.Equal(echo, frame) {
+			return nil, fmt.Errorf("%w: echo %x does not match the request %x", modbus.ErrProtocolError, echo, frame)
+		}
+	}
+	if unitID == 0 {
+		// Nobody answers a broadcast
+		return nil, errNoResponse
+	}
+
+	res, err := readASCIIFrame(link)
+	if err != nil {
+		return nil, t.fail(err)
+	}
+	if res[0] != unitID {
+		return nil, modbus.ErrBadUnitId
+	}
+	return res[1 : len(res)-1], nil
+}
+
+// Reads a frame up to its line feed and returns its bytes with the LRC. Anything before the
+// colon is noise on the line and skipped.
+func readASCIIFrame(r io.Reader) ([]byte, error) {
+	line := make([]byte, 0, 2*256+1)
+	started := false
+	b := make([]byte, 1)
+	for {
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		switch {
+		case b[0] == ':':
+			// A colon always starts a new frame
+			line = line[:0]
+			started = true
+		case !started:
+		case b[0] == '\n':
+			frame, err := hex.DecodeString(string(bytes.TrimSuffix(line, []byte("\r"))))
+			if err != nil || len(frame) < 3 {
+				return nil, fmt.Errorf("%w: invalid ascii frame %q", modbus.ErrProtocolError, line)
+			}
+			if lrc(frame[:len(frame)-1]) != frame[len(frame)-1] {
+				return nil, modbus.ErrBadCRC
+			}
+			return frame, nil
+		case len(line) == cap(line):
+			return nil, fmt.Errorf("%w: ascii frame too long", modbus.ErrProtocolError)
+		default:
+			line = append(line, b[0])
+		}
+	}
+}
+
+// Longitudinal redundancy check of an ASCII frame, the two's complement of the byte sum
+func lrc(data []byte) byte {
+	var sum byte
+	for _, b := range data {
+		sum += b
+	}
+	return -sum
+}
@@ -0,0 +1,131 @@
+package viammodbus
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"go.viam.com/rdk/logging"
+)
+
+// Line settings tried by auto detection unless candidates are configured, the common
+// 8E1 and 8N1 settings from the slowest speed up
+var defaultSerialCandidates = []serialCandidate{
+	{Speed: 9600, Parity: "even", StopBits: 1},
+	{Speed: 9600, Parity: "none", StopBits: 1},
+	{Speed: 19200, Parity: "even", StopBits: 1},
+	{Speed: 19200, Parity: "none", StopBits: 1},
+	{Speed: 38400, Parity: "even", StopBits: 1},
+	{Speed: 38400, Parity: "none", StopBits: 1},
+	{Speed: 57600, Parity: "even", StopBits: 1},
+	{Speed: 57600, Parity: "none", StopBits: 1},
+	{Speed: 115200, Parity: "even", StopBits: 1},
+	{Speed: 115200, Parity: "none", StopBits: 1},
+}
+
+// Tries the candidates in order and uses the first one a known unit id answers with
+type autoDetectConfig struct {
+	Candidates []serialCandidate `json:"candidates"`
+	UnitID     int               `json:"unit_id"`
+	Register   int               `json:"register"`
+	Timeout    int               `json:"timeout_ms"`
+}
+
+type serialCandidate struct {
+	Speed    uint   `json:"speed"`
+	Parity   string `json:"parity"`
+	StopBits uint   `json:"stop_bits"`
+}
+
+func (cfg *autoDetectConfig) validate(rawURL string) error {
+	if u, err := url.Parse(rawURL); err != nil || (u.Scheme != "rtu" && u.Scheme != asciiScheme) {
+		return fmt.Errorf("auto_detect requires an rtu:// or ascii:// url")
+	}
+	for i, c := range cfg.Candidates {
+		if c.Speed == 0 {
+			return fmt.Errorf("speed is required in auto_detect candidate %v", i)
+		}
+		if _, err := GetParity(c.Parity); c.Parity != "" && err != nil {
+			return fmt.Errorf("parity must be %v, %v or %v in auto_detect candidate %v, got %q", "none", "even", "odd", i, c.Parity)
+		}
+		if c.StopBits > 2 {
+			return fmt.Errorf("stop_bits must be 1 or 2 in auto_detect candidate %v, got %d", i, c.StopBits)
+		}
+	}
+	if cfg.UnitID != 0 && (cfg.UnitID < 1 || cfg.UnitID > 247) {
+		return fmt.Errorf("auto_detect unit_id must be between 1 and 247, got %d", cfg.UnitID)
+	}
+	if cfg.Register < 0 || cfg.Register > 65535 {
+		return fmt.Errorf("auto_detect register must be between 0 and 65535, got %d", cfg.Register)
+	}
+	if cfg.Timeout < 0 {
+		return fmt.Errorf("auto_detect timeout_ms must be non-negative, got %d", cfg.Timeout)
+	}
+	return nil
+}
+
+// Probes the candidates and returns the first working one
+func detectSerialSettings(ctx context.Context, cfg *modbusClientConfig, logger logging.Logger) (*serialCandidate, error) {
+	candidates := cfg.AutoDetect.Candidates
+	if len(candidates) == 0 {
+		candidates = defaultSerialCandidates
+	}
+	unitID := cfg.AutoDetect.UnitID
+	if unitID == 0 {
+		unitID = 1
+	}
+
+	for _, c := range candidates {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		parity, _ := GetParity(c.Parity)
+		err := probeDevice(transportConfig{
+			URL:             cfg.URL,
+			Speed:           c.Speed,
+			DataBits:        cfg.DataBits,
+			Parity:          parity,
+			StopBits:        c.StopBits,
+			Timeout:         time.Duration(cfg.AutoDetect.Timeout) * time.Millisecond,
+			RS485:           cfg.RS485.serialConfig(),
+			EchoSuppression: cfg.EchoSuppression,
+		}, uint8(unitID), uint16(cfg.AutoDetect.Register), logger)
+		if err != nil {
+			logger.Debugf("No response with speed %d, parity %q and stop bits %d: %v", c.Speed, c.Parity, c.StopBits, err)
+			continue
+		}
+		logger.Infof("Detected serial settings of %s: speed %d, parity %q, stop bits %d", cfg.URL, c.Speed, c.Parity, c.StopBits)
+		return &c, nil
+	}
+	return nil, fmt.Errorf("auto_detect found no settings unit id %d answers with on %s", unitID, cfg.URL)
+}
+
+// Returns the line settings the client uses
+func (mc *modbusClient) serialSettings() map[string]interface{} {
+	// Report the defaults the Modbus library applies to unset settings
+	speed, dataBits, stopBits := mc.config.Speed, mc.config.DataBits, mc.config.StopBits
+	if speed == 0 {
+		speed = 19200
+	}
+	if dataBits == 0 {
+		dataBits = 8
+		if isASCIIURL(mc.url) {
+			dataBits = 7
+		}
+	}
+	parity := parityName(mc.config.Parity)
+	if stopBits == 0 {
+		stopBits = 1
+		if parity == "none" {
+			stopBits = 2
+		}
+	}
+	return map[string]interface{}{
+		"speed":         speed,
+		"data_bits":     dataBits,
+		"parity":        parity,
+		"stop_bits":     stopBits,
+		"auto_detected": mc.autoDetected,
+	}
+}
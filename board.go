@@ -0,0 +1,236 @@
+package viammodbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	pb "go.viam.com/api/component/board/v1"
+	"go.viam.com/rdk/components/board"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+)
+
+var ModbusBoardModel = NamespaceFamily.WithModel("board")
+
+func init() {
+	resource.RegisterComponent(
+		board.API,
+		ModbusBoardModel,
+		resource.Registration[board.Board, *boardConfig]{
+			Constructor: newModbusBoard,
+		})
+}
+
+type boardConfig struct {
+	ModbusClient string           `json:"modbus_connection_name"`
+	UnitID       int              `json:"unit_id"`
+	GPIOs        []boardPinConfig `json:"gpios"`
+	Analogs      []boardPinConfig `json:"analogs"`
+}
+
+// A named GPIO pin or analog mapped to a coil, discrete input or register
+type boardPinConfig struct {
+	Name     string  `json:"name"`
+	Type     string  `json:"type"`
+	Offset   int     `json:"offset"`
+	DataType string  `json:"data_type"`
+	Scale    float64 `json:"scale"`
+	Min      float32 `json:"min"`
+	Max      float32 `json:"max"`
+}
+
+func (c *boardPinConfig) point() *pointConfig {
+	return &pointConfig{Type: c.Type, Offset: c.Offset, DataType: c.DataType, Scale: c.Scale}
+}
+
+func (cfg *boardConfig) Validate(path string) ([]string, []string, error) {
+	if cfg.ModbusClient == "" {
+		return nil, nil, resource.NewConfigValidationFieldRequiredError(path, "modbus_connection_name")
+	}
+	if _, err := unitIDOrDefault(cfg.UnitID); err != nil {
+		return nil, nil, err
+	}
+	names := map[string]bool{}
+	for i, g := range cfg.GPIOs {
+		if g.Name == "" {
+			return nil, nil, fmt.Errorf("name is required in gpio %v", i)
+		}
+		if names[g.Name] {
+			return nil, nil, fmt.Errorf("gpio name '%s' appears more than once", g.Name)
+		}
+		names[g.Name] = true
+		if g.Type != pointCoil && g.Type != pointDiscreteInput {
+			return nil, nil, fmt.Errorf("type must be %v or %v in gpio %v (%s), got %q", pointCoil, pointDiscreteInput, i, g.Name, g.Type)
+		}
+		if err := g.point().validate(fmt.Sprintf("gpio %v (%s)", i, g.Name), false); err != nil {
+			return nil, nil, err
+		}
+	}
+	names = map[string]bool{}
+	for i, a := range cfg.Analogs {
+		if a.Name == "" {
+			return nil, nil, fmt.Errorf("name is required in analog %v", i)
+		}
+		if names[a.Name] {
+			return nil, nil, fmt.Errorf("analog name '%s' appears more than once", a.Name)
+		}
+		names[a.Name] = true
+		if a.Type != pointHoldingRegister && a.Type != pointInputRegister {
+			return nil, nil, fmt.Errorf("type must be %v or %v in analog %v (%s), got %q", pointHoldingRegister, pointInputRegister, i, a.Name, a.Type)
+		}
+		if a.DataType == "float32" {
+			return nil, nil, fmt.Errorf("data_type float32 is not supported in analog %v (%s), analog values are integers", i, a.Name)
+		}
+		if err := a.point().validate(fmt.Sprintf("analog %v (%s)", i, a.Name), false); err != nil {
+			return nil, nil, err
+		}
+	}
+	return []string{cfg.ModbusClient}, nil, nil
+}
+
+type modbusBoard struct {
+	resource.AlwaysRebuild
+	resource.Named
+	logger  logging.Logger
+	mu      sync.Mutex
+	mc      *modbusClient
+	unitID  uint8
+	gpios   map[string]*modbusGPIOPin
+	analogs map[string]*modbusAnalog
+}
+
+func newModbusBoard(ctx context.Context, deps resource.Dependencies, conf resource.Config, logger logging.Logger) (board.Board, error) {
+	newConf, err := resource.NativeConfig[*boardConfig](conf)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &modbusBoard{
+		Named:   conf.ResourceName().AsNamed(),
+		logger:  logger,
+		gpios:   map[string]*modbusGPIOPin{},
+		analogs: map[string]*modbusAnalog{},
+	}
+	b.unitID, err = unitIDOrDefault(newConf.UnitID)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := GlobalClientRegistry.Get(newConf.ModbusClient)
+	if err != nil {
+		return nil, err
+	}
+	b.mc = client
+
+	for _, g := range newConf.GPIOs {
+		b.gpios[g.Name] = &modbusGPIOPin{board: b, point: g.point()}
+	}
+	for _, a := range newConf.Analogs {
+		b.analogs[a.Name] = &modbusAnalog{board: b, point: a.point(), min: a.Min, max: a.Max}
+	}
+	return b, nil
+}
+
+func (b *modbusBoard) AnalogByName(name string) (board.Analog, error) {
+	a, got := b.analogs[name]
+	if !got {
+		return nil, fmt.Errorf("unknown analog %q", name)
+	}
+	return a, nil
+}
+
+func (b *modbusBoard) DigitalInterruptByName(name string) (board.DigitalInterrupt, error) {
+	return nil, errors.New("digital interrupts are not supported by the modbus board")
+}
+
+func (b *modbusBoard) GPIOPinByName(name string) (board.GPIOPin, error) {
+	g, got := b.gpios[name]
+	if !got {
+		return nil, fmt.Errorf("unknown gpio pin %q", name)
+	}
+	return g, nil
+}
+
+func (b *modbusBoard) SetPowerMode(ctx context.Context, mode pb.PowerMode, duration *time.Duration) error {
+	return errors.New("power modes are not supported by the modbus board")
+}
+
+func (b *modbusBoard) StreamTicks(ctx context.Context, interrupts []board.DigitalInterrupt, ch chan board.Tick, extra map[string]interface{}) error {
+	return errors.New("digital interrupts are not supported by the modbus board")
+}
+
+func (b *modbusBoard) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	return nil, fmt.Errorf("DoCommand not implemented")
+}
+
+func (b *modbusBoard) Close(ctx context.Context) error {
+	return nil
+}
+
+// A GPIO pin backed by a coil or a read-only discrete input
+type modbusGPIOPin struct {
+	board *modbusBoard
+	point *pointConfig
+}
+
+func (g *modbusGPIOPin) Set(ctx context.Context, high bool, extra map[string]interface{}) error {
+	if g.point.Type != pointCoil {
+		return fmt.Errorf("gpio pin at discrete input %d is read-only", g.point.Offset)
+	}
+	g.board.mu.Lock()
+	defer g.board.mu.Unlock()
+	return g.point.writeRaw(g.board.mc, g.board.unitID, boolToFloat(high))
+}
+
+func (g *modbusGPIOPin) Get(ctx context.Context, extra map[string]interface{}) (bool, error) {
+	g.board.mu.Lock()
+	defer g.board.mu.Unlock()
+	return g.point.readBool(g.board.mc, g.board.unitID)
+}
+
+func (g *modbusGPIOPin) PWM(ctx context.Context, extra map[string]interface{}) (float64, error) {
+	return 0, errors.New("PWM is not supported by the modbus board")
+}
+
+func (g *modbusGPIOPin) SetPWM(ctx context.Context, dutyCyclePct float64, extra map[string]interface{}) error {
+	return errors.New("PWM is not supported by the modbus board")
+}
+
+func (g *modbusGPIOPin) PWMFreq(ctx context.Context, extra map[string]interface{}) (uint, error) {
+	return 0, errors.New("PWM is not supported by the modbus board")
+}
+
+func (g *modbusGPIOPin) SetPWMFreq(ctx context.Context, freqHz uint, extra map[string]interface{}) error {
+	return errors.New("PWM is not supported by the modbus board")
+}
+
+// An analog backed by an input or holding register. The raw register value is returned
+// as the analog value and the scale as the step size.
+type modbusAnalog struct {
+	board    *modbusBoard
+	point    *pointConfig
+	min, max float32
+}
+
+func (a *modbusAnalog) Read(ctx context.Context, extra map[string]interface{}) (board.AnalogValue, error) {
+	a.board.mu.Lock()
+	defer a.board.mu.Unlock()
+	v, err := a.point.readRaw(a.board.mc, a.board.unitID)
+	if err != nil {
+		return board.AnalogValue{}, err
+	}
+	return board.AnalogValue{Value: int(v), Min: a.min, Max: a.max, StepSize: float32(a.point.scale())}, nil
+}
+
+// Writes the raw value to the holding register
+func (a *modbusAnalog) Write(ctx context.Context, value int, extra map[string]interface{}) error {
+	if a.point.Type != pointHoldingRegister {
+		return fmt.Errorf("analog at input register %d is read-only", a.point.Offset)
+	}
+	a.board.mu.Lock()
+	defer a.board.mu.Unlock()
+	return a.point.writeRaw(a.board.mc, a.board.unitID, float64(value))
+}
@@ -0,0 +1,172 @@
+package viammodbus
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/simonvetter/modbus"
+	"go.viam.com/rdk/logging"
+)
+
+// Sends a request PDU for a unit id and returns the response PDU
+type pduTransport interface {
+	exchange(unitID uint8, req []byte) ([]byte, error)
+	Close() error
+}
+
+var (
+	// Returned by a transport to leave a request unanswered
+	errNoResponse = errors.New("no response")
+	// Returned by a transport to close the connection of the client
+	errDropConnection = errors.New("connection dropped")
+)
+
+// A Modbus TCP listener on the loopback interface that passes every request to a transport.
+// The Modbus library client connects to it, so the module can serve requests in process or
+// over its own transports while the library keeps handling retries and value decoding.
+//
+// The bridge serves a single connection, the one of its client. Any other process on the host
+// could connect to the listener, so a connection is only accepted once the previous one is
+// closed. The client closes its connection before it connects again, the previous connection
+// ends at the latest when the request in flight is answered.
+type mbapBridge struct {
+	logger    logging.Logger
+	listener  net.Listener
+	transport pduTransport
+	// Time a new connection waits for the previous one to close before it is dropped
+	handover time.Duration
+
+	mu     sync.Mutex
+	closed bool
+	// Connection of the client, nil between connections. done is closed when it ends.
+	conn net.Conn
+	done chan struct{}
+	wg   sync.WaitGroup
+
+	// Passes one request at a time to the transport, like a device on a bus answers them
+	exchangeMu sync.Mutex
+}
+
+// Starts the bridge, the transport is closed with the bridge. The handover is the longest a
+// request to the transport takes.
+func newMBAPBridge(transport pduTransport, handover time.Duration, logger logging.Logger) (*mbapBridge, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	b := &mbapBridge{
+		logger:    logger,
+		listener:  listener,
+		transport: transport,
+		handover:  handover,
+	}
+	b.wg.Add(1)
+	go b.accept()
+	return b, nil
+}
+
+// URL the Modbus library client connects to
+func (b *mbapBridge) URL() string {
+	return "tcp://" + b.listener.Addr().String()
+}
+
+func (b *mbapBridge) accept() {
+	defer b.wg.Done()
+	for {
+		conn, err := b.listener.Accept()
+		if err != nil {
+			return
+		}
+		b.mu.Lock()
+		previous := b.done
+		b.mu.Unlock()
+		if previous != nil {
+			select {
+			case <-previous:
+			case <-time.After(b.handover):
+				b.logger.Warnf("Dropping connection from %v, the bridge only serves its client", conn.RemoteAddr())
+				conn.Close()
+				continue
+			}
+		}
+
+		b.mu.Lock()
+		if b.closed {
+			b.mu.Unlock()
+			conn.Close()
+			return
+		}
+		done := make(chan struct{})
+		b.conn, b.done = conn, done
+		b.mu.Unlock()
+		b.wg.Add(1)
+		go b.serve(conn, done)
+	}
+}
+
+func (b *mbapBridge) serve(conn net.Conn, done chan struct{}) {
+	defer b.wg.Done()
+	defer func() {
+		b.mu.Lock()
+		b.conn, b.done = nil, nil
+		b.mu.Unlock()
+		conn.Close()
+		close(done)
+	}()
+
+	header := make([]byte, 7)
+	for {
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		length := binary.BigEndian.Uint16(header[4:6])
+		if length < 2 || length > 254 {
+			b.logger.Debugf("Closing connection after invalid MBAP length %d", length)
+			return
+		}
+		req := make([]byte, length-1)
+		if _, err := io.ReadFull(conn, req); err != nil {
+			return
+		}
+		b.exchangeMu.Lock()
+		res, err := b.transport.exchange(header[6], req)
+		b.exchangeMu.Unlock()
+		switch {
+		case errors.Is(err, errNoResponse):
+			continue
+		case errors.Is(err, errDropConnection):
+			b.logger.Debugf("Closing connection: %v", err)
+			return
+		case err != nil:
+			// The device did not answer, tell the client like a gateway would
+			b.logger.Debugf("Request to unit id %d failed: %v", header[6], err)
+			res = exceptionPDU(req[0], modbus.ErrGWTargetFailedToRespond)
+		}
+		frame := make([]byte, 7, 7+len(res))
+		copy(frame, header[:4])
+		binary.BigEndian.PutUint16(frame[4:6], uint16(len(res)+1))
+		frame[6] = header[6]
+		if _, err := conn.Write(append(frame, res...)); err != nil {
+			return
+		}
+	}
+}
+
+func (b *mbapBridge) Close() error {
+	err := b.listener.Close()
+	b.mu.Lock()
+	b.closed = true
+	if b.conn != nil {
+		b.conn.Close()
+	}
+	b.mu.Unlock()
+	b.wg.Wait()
+	if errors.Is(err, net.ErrClosed) {
+		err = nil
+	}
+	return errors.Join(err, b.transport.Close())
+}
@@ -0,0 +1,57 @@
+package viammodbus
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"go.viam.com/rdk/logging"
+	"go.viam.com/test"
+)
+
+// Answers every request with its own PDU
+type echoTransport struct{}
+
+func (echoTransport) exchange(unitID uint8, req []byte) ([]byte, error) { return req, nil }
+
+func (echoTransport) Close() error { return nil }
+
+func TestBridgeServesOnlyItsClient(t *testing.T) {
+	bridge, err := newMBAPBridge(echoTransport{}, 100*time.Millisecond, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+	defer bridge.Close()
+	addr := bridge.URL()[len("tcp://"):]
+
+	// Sends a read of one holding register and returns the error of the exchange
+	request := func(conn net.Conn) error {
+		req := []byte{0x00, 0x01, 0x00, 0x00, 0x00, 0x06, 0x01, 0x03, 0x00, 0x00, 0x00, 0x01}
+		if _, err := conn.Write(req); err != nil {
+			return err
+		}
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		res, err := readMBAPFrame(conn)
+		if err == nil && binary.BigEndian.Uint16(res[:2]) != 1 {
+			t.Fatalf("unexpected response % x", res)
+		}
+		return err
+	}
+
+	client, err := net.Dial("tcp", addr)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, request(client), test.ShouldBeNil)
+
+	// Another connection is dropped while the client is connected
+	other, err := net.Dial("tcp", addr)
+	test.That(t, err, test.ShouldBeNil)
+	defer other.Close()
+	test.That(t, request(other), test.ShouldNotBeNil)
+	test.That(t, request(client), test.ShouldBeNil)
+
+	// The client connects again after closing its connection
+	client.Close()
+	client, err = net.Dial("tcp", addr)
+	test.That(t, err, test.ShouldBeNil)
+	defer client.Close()
+	test.That(t, request(client), test.ShouldBeNil)
+}
@@ -0,0 +1,151 @@
+package viammodbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.viam.com/rdk/components/button"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+)
+
+var ModbusButtonModel = NamespaceFamily.WithModel("button")
+
+const defaultPulseMs = 500
+
+func init() {
+	resource.RegisterComponent(
+		button.API,
+		ModbusButtonModel,
+		resource.Registration[button.Button, *buttonConfig]{
+			Constructor: newModbusButton,
+		})
+}
+
+type buttonConfig struct {
+	ModbusClient string       `json:"modbus_connection_name"`
+	UnitID       int          `json:"unit_id"`
+	Point        *pointConfig `json:"point"`
+	PulseMs      int          `json:"pulse_ms"`
+
+	// Values written to a holding register. Without release_value the register
+	// is restored to the value read before the press.
+	PressValue   *float64 `json:"press_value"`
+	ReleaseValue *float64 `json:"release_value"`
+}
+
+func (cfg *buttonConfig) Validate(path string) ([]string, []string, error) {
+	if cfg.ModbusClient == "" {
+		return nil, nil, resource.NewConfigValidationFieldRequiredError(path, "modbus_connection_name")
+	}
+	if _, err := unitIDOrDefault(cfg.UnitID); err != nil {
+		return nil, nil, err
+	}
+	if cfg.Point == nil {
+		return nil, nil, resource.NewConfigValidationFieldRequiredError(path, "point")
+	}
+	if err := cfg.Point.validate("point", true); err != nil {
+		return nil, nil, err
+	}
+	if cfg.PulseMs < 0 {
+		return nil, nil, fmt.Errorf("pulse_ms must be non-negative, got %d", cfg.PulseMs)
+	}
+	if cfg.Point.Type == pointCoil && (cfg.PressValue != nil || cfg.ReleaseValue != nil) {
+		return nil, nil, errors.New("press_value and release_value are only supported for holding registers")
+	}
+	return []string{cfg.ModbusClient}, nil, nil
+}
+
+type modbusButton struct {
+	resource.AlwaysRebuild
+	resource.Named
+	mu     sync.Mutex
+	logger logging.Logger
+	mc     *modbusClient
+	unitID uint8
+	cfg    *buttonConfig
+	pulse  time.Duration
+}
+
+func newModbusButton(ctx context.Context, deps resource.Dependencies, conf resource.Config, logger logging.Logger) (button.Button, error) {
+	newConf, err := resource.NativeConfig[*buttonConfig](conf)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &modbusButton{
+		Named:  conf.ResourceName().AsNamed(),
+		logger: logger,
+		cfg:    newConf,
+		pulse:  defaultPulseMs * time.Millisecond,
+	}
+	if newConf.PulseMs > 0 {
+		b.pulse = time.Duration(newConf.PulseMs) * time.Millisecond
+	}
+	b.unitID, err = unitIDOrDefault(newConf.UnitID)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := GlobalClientRegistry.Get(newConf.ModbusClient)
+	if err != nil {
+		return nil, err
+	}
+	b.mc = client
+	return b, nil
+}
+
+// Presses the button for the pulse width and releases it. The release is written even
+// when the press fails or the context is cancelled during the pulse, so the machine never
+// sees a held button.
+func (b *modbusButton) Push(ctx context.Context, extra map[string]interface{}) (err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	press, release := 1.0, 0.0
+	if b.cfg.Point.Type == pointHoldingRegister {
+		if b.cfg.PressValue != nil {
+			press = *b.cfg.PressValue
+		}
+		if b.cfg.ReleaseValue != nil {
+			release = *b.cfg.ReleaseValue
+		} else {
+			release, err = b.cfg.Point.readRaw(b.mc, b.unitID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// A press that failed may still have reached the device, for example when only the
+	// response timed out, so the release is attempted whenever the press was sent
+	defer func() {
+		if releaseErr := b.cfg.Point.writeRaw(b.mc, b.unitID, release); releaseErr != nil {
+			b.logger.Errorf("failed to release button at %v %d: %v", b.cfg.Point.Type, b.cfg.Point.Offset, releaseErr)
+			err = errors.Join(err, releaseErr)
+		}
+	}()
+	if err := b.cfg.Point.writeRaw(b.mc, b.unitID, press); err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(b.pulse)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (b *modbusButton) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	return nil, fmt.Errorf("DoCommand not implemented")
+}
+
+func (b *modbusButton) Close(ctx context.Context) error {
+	return nil
+}
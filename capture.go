@@ -0,0 +1,298 @@
+package viammodbus
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.viam.com/rdk/logging"
+)
+
+// Formats of a capture file
+const (
+	captureJSONL = "jsonl"
+	capturePcap  = "pcap"
+)
+
+// Defaults of the capture file rotation
+const (
+	defaultCaptureMaxSizeKB = 10240
+	defaultCaptureMaxFiles  = 5
+)
+
+// Records the requests and responses of a client to a file. Once the file reaches
+// max_size_kb it is renamed to path.1, older files move up to path.<max_files>.
+type captureConfig struct {
+	Path      string `json:"path"`
+	Format    string `json:"format"`
+	MaxSizeKB int    `json:"max_size_kb"`
+	MaxFiles  int    `json:"max_files"`
+}
+
+func (cfg *captureConfig) validate() error {
+	if cfg.Path == "" {
+		return fmt.Errorf("capture path is required")
+	}
+	if cfg.Format != "" && cfg.Format != captureJSONL && cfg.Format != capturePcap {
+		return fmt.Errorf("capture format must be %v or %v, got %q", captureJSONL, capturePcap, cfg.Format)
+	}
+	if cfg.MaxSizeKB < 0 {
+		return fmt.Errorf("capture max_size_kb must be non-negative, got %d", cfg.MaxSizeKB)
+	}
+	if cfg.MaxFiles < 0 {
+		return fmt.Errorf("capture max_files must be non-negative, got %d", cfg.MaxFiles)
+	}
+	return nil
+}
+
+// A request and its response, one JSON line of a capture file. The PDUs are hex encoded,
+// a request without response carries the error instead.
+type captureRecord struct {
+	Time         time.Time `json:"time"`
+	UnitID       uint8     `json:"unit_id"`
+	FunctionCode uint8     `json:"function_code"`
+	Request      string    `json:"request"`
+	Response     string    `json:"response,omitempty"`
+	LatencyMs    float64   `json:"latency_ms"`
+	Error        string    `json:"error,omitempty"`
+}
+
+// Writes every exchange of the wrapped transport to the capture file
+type captureTransport struct {
+	pduTransport
+	logger logging.Logger
+	cfg    captureConfig
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+	// Sequence numbers of the TCP segments written to a pcap file
+	txnID  uint16
+	seqReq uint32
+	seqRes uint32
+}
+
+func newCaptureTransport(transport pduTransport, cfg captureConfig, logger logging.Logger) (*captureTransport, error) {
+	if cfg.Format == "" {
+		cfg.Format = captureJSONL
+	}
+	if cfg.MaxSizeKB == 0 {
+		cfg.MaxSizeKB = defaultCaptureMaxSizeKB
+	}
+	if cfg.MaxFiles == 0 {
+		cfg.MaxFiles = defaultCaptureMaxFiles
+	}
+	c := &captureTransport{pduTransport: transport, logger: logger, cfg: cfg}
+	if err := c.open(); err != nil {
+		return nil, err
+	}
+	logger.Infof("Capturing modbus traffic to %s", cfg.Path)
+	return c, nil
+}
+
+func (c *captureTransport) open() error {
+	file, err := os.OpenFile(c.cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open capture file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	c.file = file
+	c.size = info.Size()
+	if c.cfg.Format == capturePcap && c.size == 0 {
+		return c.write(pcapFileHeader())
+	}
+	return nil
+}
+
+// Moves path to path.1, path.1 to path.2 and so on, dropping the oldest file
+func (c *captureTransport) rotate() error {
+	if err := c.file.Close(); err != nil {
+		return err
+	}
+	os.Remove(fmt.Sprintf("%s.%d", c.cfg.Path, c.cfg.MaxFiles))
+	for i := c.cfg.MaxFiles - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", c.cfg.Path, i), fmt.Sprintf("%s.%d", c.cfg.Path, i+1))
+	}
+	if c.cfg.MaxFiles > 0 {
+		if err := os.Rename(c.cfg.Path, c.cfg.Path+".1"); err != nil {
+			return err
+		}
+	}
+	return c.open()
+}
+
+func (c *captureTransport) write(b []byte) error {
+	n, err := c.file.Write(b)
+	c.size += int64(n)
+	return err
+}
+
+func (c *captureTransport) exchange(unitID uint8, req []byte) ([]byte, error) {
+	start := time.Now()
+	res, err := c.pduTransport.exchange(unitID, req)
+	latency := time.Since(start)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var entry []byte
+	if c.cfg.Format == capturePcap {
+		entry = c.pcapPackets(start, latency, unitID, req, res)
+	} else {
+		entry = captureLine(start, latency, unitID, req, res, err)
+	}
+	if c.size > 0 && c.size+int64(len(entry)) > int64(c.cfg.MaxSizeKB)*1024 {
+		if rErr := c.rotate(); rErr != nil {
+			c.logger.Errorf("Failed to rotate capture file: %v", rErr)
+		}
+	}
+	if wErr := c.write(entry); wErr != nil {
+		c.logger.Errorf("Failed to write capture file: %v", wErr)
+	}
+	return res, err
+}
+
+func captureLine(start time.Time, latency time.Duration, unitID uint8, req, res []byte, err error) []byte {
+	r := captureRecord{
+		Time:         start.UTC(),
+		UnitID:       unitID,
+		FunctionCode: req[0],
+		Request:      hex.EncodeToString(req),
+		Response:     hex.EncodeToString(res),
+		LatencyMs:    float64(latency.Microseconds()) / 1000,
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	line, _ := json.Marshal(r)
+	return append(line, '\n')
+}
+
+func (c *captureTransport) Close() error {
+	c.mu.Lock()
+	err := c.file.Close()
+	c.mu.Unlock()
+	return errors.Join(c.pduTransport.Close(), err)
+}
+
+// The pcap file holds raw IPv4 packets of a Modbus TCP session between 10.0.0.1 and a
+// device at 10.0.0.2 port 502, so Wireshark decodes them like traffic captured on a network
+const (
+	pcapHeaderSize    = 24
+	pcapLinkTypeRaw   = 101
+	pcapClientPort    = 50200
+	pcapServerPort    = 502
+	pcapIPHeaderSize  = 20
+	pcapTCPHeaderSize = 20
+)
+
+func pcapFileHeader() []byte {
+	h := make([]byte, pcapHeaderSize)
+	binary.LittleEndian.PutUint32(h[0:], 0xa1b2c3d4)
+	binary.LittleEndian.PutUint16(h[4:], 2)
+	binary.LittleEndian.PutUint16(h[6:], 4)
+	binary.LittleEndian.PutUint32(h[16:], 65535)
+	binary.LittleEndian.PutUint32(h[20:], pcapLinkTypeRaw)
+	return h
+}
+
+// Returns the request and, if any, the response as pcap records
+func (c *captureTransport) pcapPackets(start time.Time, latency time.Duration, unitID uint8, req, res []byte) []byte {
+	c.txnID++
+	out := c.pcapPacket(start, true, c.mbapFrame(unitID, req))
+	if res != nil {
+		out = append(out, c.pcapPacket(start.Add(latency), false, c.mbapFrame(unitID, res))...)
+	}
+	return out
+}
+
+func (c *captureTransport) mbapFrame(unitID uint8, pdu []byte) []byte {
+	frame := make([]byte, 7, 7+len(pdu))
+	binary.BigEndian.PutUint16(frame[0:], c.txnID)
+	binary.BigEndian.PutUint16(frame[4:], uint16(len(pdu)+1))
+	frame[6] = unitID
+	return append(frame, pdu...)
+}
+
+func (c *captureTransport) pcapPacket(t time.Time, request bool, payload []byte) []byte {
+	src, dst := []byte{10, 0, 0, 1}, []byte{10, 0, 0, 2}
+	srcPort, dstPort := uint16(pcapClientPort), uint16(pcapServerPort)
+	seq, ack := &c.seqReq, c.seqRes
+	if !request {
+		src, dst = dst, src
+		srcPort, dstPort = dstPort, srcPort
+		seq, ack = &c.seqRes, c.seqReq
+	}
+	length := pcapIPHeaderSize + pcapTCPHeaderSize + len(payload)
+
+	ip := make([]byte, pcapIPHeaderSize)
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:], uint16(length))
+	ip[8] = 64
+	ip[9] = 6
+	copy(ip[12:], src)
+	copy(ip[16:], dst)
+	binary.BigEndian.PutUint16(ip[10:], ipChecksum(ip))
+
+	tcp := make([]byte, pcapTCPHeaderSize)
+	binary.BigEndian.PutUint16(tcp[0:], srcPort)
+	binary.BigEndian.PutUint16(tcp[2:], dstPort)
+	binary.BigEndian.PutUint32(tcp[4:], *seq)
+	binary.BigEndian.PutUint32(tcp[8:], ack)
+	tcp[12] = pcapTCPHeaderSize / 4 << 4
+	tcp[13] = 0x18 // PSH, ACK
+	binary.BigEndian.PutUint16(tcp[14:], 65535)
+	*seq += uint32(len(payload))
+
+	record := make([]byte, 16, 16+length)
+	binary.LittleEndian.PutUint32(record[0:], uint32(t.Unix()))
+	binary.LittleEndian.PutUint32(record[4:], uint32(t.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(record[8:], uint32(length))
+	binary.LittleEndian.PutUint32(record[12:], uint32(length))
+	record = append(record, ip...)
+	record = append(record, tcp...)
+	return append(record, payload...)
+}
+
+func ipChecksum(header []byte) uint16 {
+	var sum uint32
+	for i := 0; i < len(header); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(header[i:]))
+	}
+	for sum > 0xFFFF {
+		sum = sum>>16 + sum&0xFFFF
+	}
+	return ^uint16(sum)
+}
+
+// Reads the records of a JSON Lines capture file
+func readCapture(path string) ([]captureRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open capture file: %w", err)
+	}
+	defer file.Close()
+
+	records := []captureRecord{}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var r captureRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return nil, fmt.Errorf("failed to parse capture file %s line %d: %w", path, line, err)
+		}
+		records = append(records, r)
+	}
+	return records, scanner.Err()
+}
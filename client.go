@@ -2,7 +2,10 @@ package viammodbus
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/url"
+	"os"
 	"sync"
 	"time"
 
@@ -27,22 +30,47 @@ func init() {
 }
 
 type modbusClientConfig struct {
-	URL           string `json:"url"`
-	Speed         uint   `json:"speed"`
-	DataBits      uint   `json:"data_bits"`
-	Parity        uint   `json:"parity"`
-	StopBits      uint   `json:"stop_bits"`
-	Timeout       int    `json:"timeout_ms"`
-	Endianness    string `json:"endianness"`
-	WordOrder     string `json:"word_order"`
-	TLSClientCert string `json:"tls_client_cert"`
-	TLSRootCAs    string `json:"tls_root_cas"`
+	URL           string      `json:"url"`
+	Speed         uint        `json:"speed"`
+	DataBits      uint        `json:"data_bits"`
+	Parity        interface{} `json:"parity"`
+	StopBits      uint        `json:"stop_bits"`
+	Timeout       int         `json:"timeout_ms"`
+	Endianness    string      `json:"endianness"`
+	WordOrder     string      `json:"word_order"`
+	TLSClientCert string      `json:"tls_client_cert"`
+	TLSRootCAs    string      `json:"tls_root_cas"`
+
+	// RS-485 driver settings of an rtu:// url
+	RS485 *rs485Config `json:"rs485"`
+	// Discards the request frames a two-wire RS-485 adapter echoes back
+	EchoSuppression bool `json:"echo_suppression"`
+
+	// Register bank of a sim:// url
+	Simulation *simConfig `json:"simulation"`
+	// Records the traffic of the client
+	Capture *captureConfig `json:"capture"`
+	// Detects speed, parity and stop bits of an rtu:// url
+	AutoDetect *autoDetectConfig `json:"auto_detect"`
 }
 
 func (cfg *modbusClientConfig) Validate(path string) ([]string, []string, error) {
 	if cfg.URL == "" {
 		return nil, nil, fmt.Errorf("url is required")
 	}
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid url %q: %w", cfg.URL, err)
+	}
+	if err := validateURL(u); err != nil {
+		return nil, nil, err
+	}
+	if err := cfg.validateSerial(u.Scheme); err != nil {
+		return nil, nil, err
+	}
+	if cfg.Timeout < 0 {
+		return nil, nil, fmt.Errorf("timeout_ms must be non-negative, got %d", cfg.Timeout)
+	}
 	if cfg.Endianness != "" && cfg.Endianness != "big" && cfg.Endianness != "little" {
 		return nil, nil, fmt.Errorf("endianness must be %v or %v", "big", "little")
 	}
@@ -52,6 +80,38 @@ func (cfg *modbusClientConfig) Validate(path string) ([]string, []string, error)
 	if cfg.TLSClientCert != "" || cfg.TLSRootCAs != "" {
 		fmt.Println("Warning: TLS is not supported yet, TLSClientCert and TLSRootCAs will be ignored")
 	}
+	if isSimURL(cfg.URL) {
+		if _, err := loadSimConfig(cfg.URL, cfg.Simulation); err != nil {
+			return nil, nil, err
+		}
+	} else if cfg.Simulation != nil {
+		return nil, nil, fmt.Errorf("simulation requires a %v:// url", simScheme)
+	}
+	if isReplayURL(cfg.URL) {
+		path, err := replayPath(cfg.URL)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, err := os.Stat(path); err != nil {
+			return nil, nil, fmt.Errorf("failed to read capture file: %w", err)
+		}
+	}
+	if cfg.AutoDetect != nil {
+		if err := cfg.AutoDetect.validate(cfg.URL); err != nil {
+			return nil, nil, err
+		}
+		if cfg.Speed != 0 || cfg.Parity != nil || cfg.StopBits != 0 {
+			fmt.Println("Warning: auto_detect is set, speed, parity and stop_bits will be replaced by the detected settings")
+		}
+	}
+	if cfg.Capture != nil {
+		if err := cfg.Capture.validate(); err != nil {
+			return nil, nil, err
+		}
+		if u.Scheme == "tcp+tls" {
+			return nil, nil, fmt.Errorf("capture is not supported for tcp+tls urls")
+		}
+	}
 	return []string{}, nil, nil
 }
 
@@ -66,6 +126,14 @@ type modbusClient struct {
 
 	client *modbus.ModbusClient
 	config modbus.ClientConfiguration
+	// Configured url, config holds the url of the bridge if there is one
+	url string
+	// True if the serial settings were found by auto_detect
+	autoDetected bool
+
+	// In-process listener the Modbus library client connects to when the module handles the
+	// transport itself, for simulation, replay and capture
+	bridge *mbapBridge
 }
 
 func newModbusClient(ctx context.Context, deps resource.Dependencies, config resource.Config, logger logging.Logger) (generic.Resource, error) {
@@ -74,16 +142,35 @@ func newModbusClient(ctx context.Context, deps resource.Dependencies, config res
 		return nil, err
 	}
 
+	autoDetected := false
+	if newConf.AutoDetect != nil {
+		detected, err := detectSerialSettings(ctx, newConf, logger)
+		if err != nil {
+			return nil, err
+		}
+		// Work on a copy, the configuration is owned by the caller
+		detectedConf := *newConf
+		detectedConf.Speed = detected.Speed
+		detectedConf.Parity = detected.Parity
+		detectedConf.StopBits = detected.StopBits
+		newConf = &detectedConf
+		autoDetected = true
+	}
+
 	var timeout time.Duration
 	if newConf.Timeout > 0 {
 		timeout = time.Millisecond * time.Duration(newConf.Timeout)
 	}
+	parity, err := parseParity(newConf.Parity)
+	if err != nil {
+		return nil, err
+	}
 
 	clientConfig := modbus.ClientConfiguration{
 		URL:      newConf.URL,
 		Speed:    newConf.Speed,
 		DataBits: newConf.DataBits,
-		Parity:   newConf.Parity,
+		Parity:   parity,
 		StopBits: newConf.StopBits,
 		Timeout:  timeout,
 		//TODO: Add TLS support
@@ -94,13 +181,34 @@ func newModbusClient(ctx context.Context, deps resource.Dependencies, config res
 	client := &modbusClient{
 		name:   config.ResourceName(),
 		logger: logger,
+		url:    newConf.URL,
 		config: clientConfig,
+
+		autoDetected: autoDetected,
+	}
+
+	transport, bridgeTimeout, err := newClientTransport(newConf, parity, timeout, logger)
+	if err != nil {
+		return nil, err
+	}
+	if transport != nil {
+		client.bridge, err = newMBAPBridge(transport, bridgeTimeout, logger)
+		if err != nil {
+			transport.Close()
+			return nil, err
+		}
+		clientConfig.URL = client.bridge.URL()
+		clientConfig.Timeout = bridgeTimeout
+		client.config = clientConfig
 	}
 
 	// Create the modbus connection with the provided configuration
 	err = client.newModbusConnection(&clientConfig)
 	if err != nil {
 		logger.Errorf("Failed to create modbus client: %#v", err)
+		if client.bridge != nil {
+			client.bridge.Close()
+		}
 		return nil, err
 	}
 
@@ -116,6 +224,63 @@ func newModbusClient(ctx context.Context, deps resource.Dependencies, config res
 	return client, nil
 }
 
+// Returns the transport the client reaches the device through over the bridge, nil if the
+// Modbus library connects to the device itself. The returned timeout is the one of the
+// library client, it outlasts the device timeout so failed requests are answered by the bridge.
+func newClientTransport(cfg *modbusClientConfig, parity uint, timeout time.Duration, logger logging.Logger) (pduTransport, time.Duration, error) {
+	var transport pduTransport
+	bridgeTimeout := timeout
+	switch {
+	case isSimURL(cfg.URL):
+		// Connect to an in-process simulated device instead of a real transport
+		simConf, err := loadSimConfig(cfg.URL, cfg.Simulation)
+		if err != nil {
+			return nil, 0, err
+		}
+		endianness, wordOrder := simEncoding(cfg.Endianness, cfg.WordOrder)
+		transport, err = newSimulator(simConf, endianness, wordOrder, logger)
+		if err != nil {
+			return nil, 0, err
+		}
+	case isReplayURL(cfg.URL):
+		var err error
+		transport, err = newReplayTransport(cfg.URL, logger)
+		if err != nil {
+			return nil, 0, err
+		}
+	case cfg.Capture != nil || cfg.RS485 != nil || cfg.EchoSuppression || isASCIIURL(cfg.URL):
+		// The Modbus library has no RS-485 settings, echo handling or ASCII framing, its
+		// serial ports are opened without them
+		device, err := newDeviceTransport(transportConfig{
+			URL:             cfg.URL,
+			Speed:           cfg.Speed,
+			DataBits:        cfg.DataBits,
+			Parity:          parity,
+			StopBits:        cfg.StopBits,
+			Timeout:         timeout,
+			RS485:           cfg.RS485.serialConfig(),
+			EchoSuppression: cfg.EchoSuppression,
+		}, logger)
+		if err != nil {
+			return nil, 0, err
+		}
+		transport = device
+		bridgeTimeout = deviceTimeout(cfg.URL, timeout) + time.Second
+	default:
+		return nil, 0, nil
+	}
+
+	if cfg.Capture != nil {
+		capture, err := newCaptureTransport(transport, *cfg.Capture, logger)
+		if err != nil {
+			transport.Close()
+			return nil, 0, err
+		}
+		transport = capture
+	}
+	return transport, bridgeTimeout, nil
+}
+
 func (mc *modbusClient) newModbusConnection(config *modbus.ClientConfiguration) error {
 	mc.logger.Infof("Creating new modbus client with config: %#v", config)
 	client, err := modbus.NewClient(config)
@@ -157,12 +322,26 @@ func setDecoding(mc *modbusClient, endianness, wordOrder string) {
 	}
 }
 
+// Returns true if the client swaps the bytes of each register, see setDecoding
+func (mc *modbusClient) swapsBytes() bool {
+	return mc.endianness != nil && mc.wordOrder != nil && *mc.endianness == modbus.LITTLE_ENDIAN
+}
+
 func (mc *modbusClient) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	if args, got := cmd["scan"]; got {
+		return mc.scan(ctx, args)
+	}
+	if _, got := cmd["serial_settings"]; got {
+		return map[string]interface{}{"serial_settings": mc.serialSettings()}, nil
+	}
 	return nil, fmt.Errorf("DoCommand not implemented")
 }
 
 func (mc *modbusClient) Close(ctx context.Context) error {
 	GlobalClientRegistry.Remove(mc.name.Name)
+	if mc.bridge != nil {
+		return mc.bridge.Close()
+	}
 	return nil
 }
 
@@ -181,7 +360,7 @@ func (mc *modbusClient) ReadCoils(offset, length uint16, unitID uint8) ([]bool,
 		if err != nil {
 			mc.logger.Debugf("Failed to read coils: %v", err)
 			availableRetries--
-			err := mc.reConnect()
+			err := mc.reConnect(err)
 			if err != nil {
 				return nil, err
 			}
@@ -202,7 +381,7 @@ func (mc *modbusClient) ReadCoil(offset uint16, unitID uint8) (bool, error) {
 		mc.mu.Unlock()
 		if err != nil {
 			availableRetries--
-			err := mc.reConnect()
+			err := mc.reConnect(err)
 			if err != nil {
 				return false, err
 			}
@@ -223,7 +402,7 @@ func (mc *modbusClient) ReadDiscreteInputs(offset, length uint16, unitID uint8)
 		mc.mu.Unlock()
 		if err != nil {
 			availableRetries--
-			err := mc.reConnect()
+			err := mc.reConnect(err)
 			if err != nil {
 				return nil, err
 			}
@@ -244,7 +423,7 @@ func (mc *modbusClient) ReadDiscreteInput(offset uint16, unitID uint8) (bool, er
 		mc.mu.Unlock()
 		if err != nil {
 			availableRetries--
-			err := mc.reConnect()
+			err := mc.reConnect(err)
 			if err != nil {
 				return false, err
 			}
@@ -265,7 +444,7 @@ func (mc *modbusClient) ReadHoldingRegisters(offset, length uint16, unitID uint8
 		mc.mu.Unlock()
 		if err != nil {
 			availableRetries--
-			err := mc.reConnect()
+			err := mc.reConnect(err)
 			if err != nil {
 				return nil, err
 			}
@@ -286,7 +465,7 @@ func (mc *modbusClient) ReadInputRegisters(offset, length uint16, unitID uint8)
 		mc.mu.Unlock()
 		if err != nil {
 			availableRetries--
-			err := mc.reConnect()
+			err := mc.reConnect(err)
 			if err != nil {
 				return nil, err
 			}
@@ -307,7 +486,7 @@ func (mc *modbusClient) ReadInt32(offset uint16, regType modbus.RegType, unitID
 		mc.mu.Unlock()
 		if err != nil {
 			availableRetries--
-			err := mc.reConnect()
+			err := mc.reConnect(err)
 			if err != nil {
 				return 0, err
 			}
@@ -328,7 +507,7 @@ func (mc *modbusClient) ReadUInt32(offset uint16, regType modbus.RegType, unitID
 		mc.mu.Unlock()
 		if err != nil {
 			availableRetries--
-			err := mc.reConnect()
+			err := mc.reConnect(err)
 			if err != nil {
 				return 0, err
 			}
@@ -349,7 +528,7 @@ func (mc *modbusClient) ReadUInt64(offset uint16, regType modbus.RegType, unitID
 		mc.mu.Unlock()
 		if err != nil {
 			availableRetries--
-			err := mc.reConnect()
+			err := mc.reConnect(err)
 			if err != nil {
 				return 0, err
 			}
@@ -370,7 +549,7 @@ func (mc *modbusClient) ReadFloat32(offset uint16, regType modbus.RegType, unitI
 		mc.mu.Unlock()
 		if err != nil {
 			availableRetries--
-			err := mc.reConnect()
+			err := mc.reConnect(err)
 			if err != nil {
 				return 0, err
 			}
@@ -391,7 +570,7 @@ func (mc *modbusClient) ReadFloat64(offset uint16, regType modbus.RegType, unitI
 		mc.mu.Unlock()
 		if err != nil {
 			availableRetries--
-			err := mc.reConnect()
+			err := mc.reConnect(err)
 			if err != nil {
 				return 0, err
 			}
@@ -412,7 +591,7 @@ func (mc *modbusClient) ReadUInt8(offset uint16, regType modbus.RegType, unitID
 		mc.mu.Unlock()
 		if err != nil {
 			availableRetries--
-			err := mc.reConnect()
+			err := mc.reConnect(err)
 			if err != nil {
 				return 0, err
 			}
@@ -433,7 +612,7 @@ func (mc *modbusClient) ReadInt16(offset uint16, regType modbus.RegType, unitID
 		mc.mu.Unlock()
 		if err != nil {
 			availableRetries--
-			err := mc.reConnect()
+			err := mc.reConnect(err)
 			if err != nil {
 				return 0, err
 			}
@@ -454,7 +633,7 @@ func (mc *modbusClient) ReadUInt16(offset uint16, regType modbus.RegType, unitID
 		mc.mu.Unlock()
 		if err != nil {
 			availableRetries--
-			err := mc.reConnect()
+			err := mc.reConnect(err)
 			if err != nil {
 				return 0, err
 			}
@@ -475,7 +654,7 @@ func (mc *modbusClient) ReadBytes(offset, length uint16, regType modbus.RegType,
 		mc.mu.Unlock()
 		if err != nil {
 			availableRetries--
-			err := mc.reConnect()
+			err := mc.reConnect(err)
 			if err != nil {
 				return nil, err
 			}
@@ -496,7 +675,7 @@ func (mc *modbusClient) ReadRawBytes(offset, length uint16, regType modbus.RegTy
 		mc.mu.Unlock()
 		if err != nil {
 			availableRetries--
-			err := mc.reConnect()
+			err := mc.reConnect(err)
 			if err != nil {
 				return nil, err
 			}
@@ -517,7 +696,7 @@ func (mc *modbusClient) WriteCoil(offset uint16, value bool, unitID uint8) error
 		mc.mu.Unlock()
 		if err != nil {
 			availableRetries--
-			err := mc.reConnect()
+			err := mc.reConnect(err)
 			if err != nil {
 				return err
 			}
@@ -528,6 +707,12 @@ func (mc *modbusClient) WriteCoil(offset uint16, value bool, unitID uint8) error
 	return ErrRetriesExhausted
 }
 
+func (mc *modbusClient) WriteCoils(offset uint16, values []bool, unitID uint8) error {
+	return mc.WriteWithRetry(func() error {
+		return mc.client.WriteCoils(offset, values)
+	}, unitID)
+}
+
 func (mc *modbusClient) WriteUInt16(offset uint16, value uint16, unitID uint8) error {
 	return mc.WriteWithRetry(func() error {
 		return mc.client.WriteRegister(offset, value)
@@ -568,7 +753,7 @@ func (mc *modbusClient) WriteWithRetry(w func() error, unitID uint8) error {
 		mc.mu.Unlock()
 		if err != nil {
 			availableRetries--
-			err := mc.reConnect()
+			err := mc.reConnect(err)
 			if err != nil {
 				return err
 			}
@@ -579,12 +764,53 @@ func (mc *modbusClient) WriteWithRetry(w func() error, unitID uint8) error {
 	return ErrRetriesExhausted
 }
 
-func (mc *modbusClient) reConnect() error {
+// Sends a single request without retries. Exception responses are returned as is, the
+// connection is only re-opened after transport errors such as timeouts.
+func (mc *modbusClient) request(r func() error, unitID uint8) error {
+	mc.mu.Lock()
+	mc.client.SetUnitId(unitID)
+	err := r()
+	mc.mu.Unlock()
+	if err != nil && !isModbusException(err) {
+		mc.logger.Debugf("Request failed: %v", err)
+		// reConnect returns the error itself for a datagram request that timed out
+		if reErr := mc.reConnect(err); reErr != nil && reErr != err {
+			mc.logger.Debugf("Failed to reconnect: %v", reErr)
+		}
+	}
+	return err
+}
+
+// Returns true if the error is an exception response sent by the device
+func isModbusException(err error) bool {
+	switch err {
+	case modbus.ErrIllegalFunction, modbus.ErrIllegalDataAddress, modbus.ErrIllegalDataValue,
+		modbus.ErrServerDeviceFailure, modbus.ErrAcknowledge, modbus.ErrServerDeviceBusy,
+		modbus.ErrMemoryParityError, modbus.ErrGWPathUnavailable, modbus.ErrGWTargetFailedToRespond:
+		return true
+	}
+	return false
+}
+
+// Re-opens the client after a request failed with err. The returned error ends the retries
+// of the request.
+func (mc *modbusClient) reConnect(err error) error {
+	if isDatagramURL(mc.url) {
+		// A device that did not answer a datagram within the timeout is unlikely to answer the
+		// next one, retrying would only wait out the timeout again
+		if errors.Is(err, modbus.ErrRequestTimedOut) || errors.Is(err, modbus.ErrGWTargetFailedToRespond) {
+			return err
+		}
+		// A udp socket has no connection to lose, re-opening it after every unanswered request
+		// only adds load on a struggling device
+		return nil
+	}
 	mc.mu.Lock()
 	defer mc.mu.Unlock()
 	mc.logger.Debugf("Re-initializing modbus client")
-	err := mc.client.Open()
-	if err != nil {
+	// Open does not close the previous transport of the client
+	mc.client.Close()
+	if err := mc.client.Open(); err != nil {
 		mc.logger.Errorf("Failed to re-open modbus client: %#v", err)
 		return err
 	}
@@ -602,6 +828,19 @@ func GetEndianness(s string) (modbus.Endianness, error) {
 	}
 }
 
+func GetParity(s string) (uint, error) {
+	switch s {
+	case "none":
+		return modbus.PARITY_NONE, nil
+	case "even":
+		return modbus.PARITY_EVEN, nil
+	case "odd":
+		return modbus.PARITY_ODD, nil
+	default:
+		return 0, fmt.Errorf("invalid parity")
+	}
+}
+
 func GetWordOrder(s string) (modbus.WordOrder, error) {
 	switch s {
 	case "high":
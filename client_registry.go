@@ -35,6 +35,19 @@ func (cr *clientRegistry) Remove(name string) error {
 	return fmt.Errorf("no client with name [%s] found", name)
 }
 
+// Returns true if a client is connected to the url
+func (cr *clientRegistry) HasURL(url string) bool {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	for _, c := range cr.clients {
+		if c.url == url {
+			return true
+		}
+	}
+	return false
+}
+
 func (cr *clientRegistry) Get(name string) (*modbusClient, error) {
 	cr.mu.Lock()
 	defer cr.mu.Unlock()
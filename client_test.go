@@ -0,0 +1,263 @@
+package viammodbus
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/simonvetter/modbus"
+	"go.viam.com/rdk/components/generic"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/test"
+)
+
+// Stand-in for a serial server or a Modbus device on the network. Unit id 1 answers reads of
+// holding registers with 42, any other unit id does not answer.
+type standIn struct {
+	addr string
+
+	mu       sync.Mutex
+	sources  []string
+	accepted int
+	closed   int
+}
+
+func (s *standIn) stats() (sources []string, accepted, closed int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.sources...), s.accepted, s.closed
+}
+
+// Returns the response PDU of the device, nil if it does not answer
+func (s *standIn) answer(source net.Addr, unitID uint8, req []byte) []byte {
+	s.mu.Lock()
+	s.sources = append(s.sources, source.String())
+	s.mu.Unlock()
+	if unitID != 1 || len(req) != 5 || req[0] != 0x03 {
+		return nil
+	}
+	quantity := binary.BigEndian.Uint16(req[3:])
+	res := []byte{0x03, byte(2 * quantity)}
+	for i := uint16(0); i < quantity; i++ {
+		res = binary.BigEndian.AppendUint16(res, 42)
+	}
+	return res
+}
+
+// Starts a stand-in speaking RTU framing over tcp or udp, or Modbus TCP framing over udp
+func startStandIn(t *testing.T, network string, rtu bool) *standIn {
+	t.Helper()
+	s := &standIn{}
+	frame := func(source net.Addr, b []byte) []byte {
+		if rtu {
+			if len(b) < 4 || crc16(b[:len(b)-2]) != binary.LittleEndian.Uint16(b[len(b)-2:]) {
+				return nil
+			}
+			res := s.answer(source, b[0], b[1:len(b)-2])
+			if res == nil {
+				return nil
+			}
+			return rtuFrame(append([]byte{b[0]}, res...)...)
+		}
+		if len(b) < 8 {
+			return nil
+		}
+		res := s.answer(source, b[6], b[7:])
+		if res == nil {
+			return nil
+		}
+		header := append([]byte{}, b[:7]...)
+		binary.BigEndian.PutUint16(header[4:6], uint16(len(res)+1))
+		return append(header, res...)
+	}
+
+	if network == "udp" {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		test.That(t, err, test.ShouldBeNil)
+		t.Cleanup(func() { conn.Close() })
+		s.addr = conn.LocalAddr().String()
+		go func() {
+			buf := make([]byte, 512)
+			for {
+				n, source, err := conn.ReadFrom(buf)
+				if err != nil {
+					return
+				}
+				if res := frame(source, buf[:n]); res != nil {
+					conn.WriteTo(res, source)
+				}
+			}
+		}()
+		return s
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	test.That(t, err, test.ShouldBeNil)
+	t.Cleanup(func() { listener.Close() })
+	s.addr = listener.Addr().String()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.accepted++
+			s.mu.Unlock()
+			go func() {
+				defer func() {
+					conn.Close()
+					s.mu.Lock()
+					s.closed++
+					s.mu.Unlock()
+				}()
+				// Reads of holding registers are the only requests of the tests, 8 bytes each
+				req := make([]byte, 8)
+				for {
+					if _, err := io.ReadFull(conn, req); err != nil {
+						return
+					}
+					if res := frame(conn.RemoteAddr(), req); res != nil {
+						conn.Write(res)
+					}
+				}
+			}()
+		}
+	}()
+	return s
+}
+
+func rtuFrame(b ...byte) []byte {
+	return binary.LittleEndian.AppendUint16(b, crc16(b))
+}
+
+func newTestClient(t *testing.T, rawURL string) *modbusClient {
+	t.Helper()
+	cfg := &modbusClientConfig{URL: rawURL, Timeout: 100}
+	_, _, err := cfg.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+	c, err := newModbusClient(context.Background(), nil, resource.Config{
+		Name:                t.Name(),
+		API:                 generic.API,
+		ConvertedAttributes: cfg,
+	}, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+	t.Cleanup(func() { c.Close(context.Background()) })
+	return c.(*modbusClient)
+}
+
+func TestClientSerialServer(t *testing.T) {
+	for _, scheme := range []string{"rtuovertcp", "rtuoverudp"} {
+		t.Run(scheme, func(t *testing.T) {
+			server := startStandIn(t, scheme[len("rtuover"):], true)
+			mc := newTestClient(t, scheme+"://"+server.addr)
+
+			values, err := mc.ReadHoldingRegisters(0, 2, 1)
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, values, test.ShouldResemble, []uint16{42, 42})
+		})
+	}
+}
+
+func TestDatagramClientKeepsSocket(t *testing.T) {
+	for _, scheme := range []string{"udp", "rtuoverudp"} {
+		t.Run(scheme, func(t *testing.T) {
+			server := startStandIn(t, "udp", scheme == "rtuoverudp")
+			mc := newTestClient(t, scheme+"://"+server.addr)
+
+			_, err := mc.ReadHoldingRegisters(0, 1, 1)
+			test.That(t, err, test.ShouldBeNil)
+			// Unit id 2 never answers, the timeout is not retried
+			start := time.Now()
+			_, err = mc.ReadHoldingRegisters(0, 1, 2)
+			test.That(t, err, test.ShouldEqual, modbus.ErrRequestTimedOut)
+			test.That(t, time.Since(start), test.ShouldBeLessThan, 200*time.Millisecond)
+			_, err = mc.ReadHoldingRegisters(0, 1, 1)
+			test.That(t, err, test.ShouldBeNil)
+
+			// A socket opened again would send from another port
+			sources, _, _ := server.stats()
+			test.That(t, sources, test.ShouldHaveLength, 3)
+			for _, source := range sources {
+				test.That(t, source, test.ShouldEqual, sources[0])
+			}
+		})
+	}
+}
+
+func TestStreamClientReconnects(t *testing.T) {
+	server := startStandIn(t, "tcp", true)
+	mc := newTestClient(t, "rtuovertcp://"+server.addr)
+
+	_, err := mc.ReadHoldingRegisters(0, 1, 2)
+	test.That(t, err, test.ShouldEqual, ErrRetriesExhausted)
+	_, err = mc.ReadHoldingRegisters(0, 1, 1)
+	test.That(t, err, test.ShouldBeNil)
+
+	// Each of the three timeouts re-opens the connection and closes the previous one
+	deadline := time.Now().Add(time.Second)
+	for {
+		_, accepted, closed := server.stats()
+		if closed == 3 || time.Now().After(deadline) {
+			test.That(t, accepted, test.ShouldEqual, 4)
+			test.That(t, closed, test.ShouldEqual, 3)
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	for _, tc := range []struct {
+		url   string
+		valid bool
+	}{
+		{"tcp://192.168.1.10:502", true},
+		{"tcp+tls://plc.local:802", true},
+		{"udp://192.168.1.10:502", true},
+		{"rtuovertcp://10.0.0.5:4001", true},
+		{"rtuoverudp://10.0.0.5:4001", true},
+		{"rtu:///dev/ttyUSB0", true},
+		{"rtu://COM3", true},
+		{"ascii:///dev/ttyUSB0", true},
+		{"sim://", true},
+		{"replay://capture.jsonl", true},
+
+		{"tcp://192.168.1.10", false},
+		{"tcp+tls://plc.local", false},
+		{"udp://192.168.1.10", false},
+		{"rtuovertcp://10.0.0.5", false},
+		{"rtuoverudp://:4001", false},
+		{"tcp://:502", false},
+		{"rtuoverudp://10.0.0.5:70000", false},
+		{"tcp://192.168.1.10:502/modbus", false},
+		{"udp://192.168.1.10:502/", false},
+		{"rtuovertcp://10.0.0.5:4001?unit=1", false},
+		{"rtuoverudp://10.0.0.5:4001/tty", false},
+		{"rtu://", false},
+		{"ascii://", false},
+		{"serial:///dev/ttyUSB0", false},
+	} {
+		t.Run(tc.url, func(t *testing.T) {
+			u, err := url.Parse(tc.url)
+			test.That(t, err, test.ShouldBeNil)
+			if tc.valid {
+				test.That(t, validateURL(u), test.ShouldBeNil)
+			} else {
+				test.That(t, validateURL(u), test.ShouldNotBeNil)
+			}
+		})
+	}
+
+	// The url parser already rejects non-numeric ports and urls without a scheme
+	for _, rawURL := range []string{"tcp://192.168.1.10:abc", "rtuovertcp://10.0.0.5:port", "192.168.1.10:502"} {
+		_, _, err := (&modbusClientConfig{URL: rawURL}).Validate("")
+		test.That(t, err, test.ShouldNotBeNil)
+	}
+}
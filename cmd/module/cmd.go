@@ -3,10 +3,17 @@ package main
 import (
 	viammodbus "github.com/viam-soleng/viam-modbus"
 
+	"go.viam.com/rdk/components/board"
+	"go.viam.com/rdk/components/button"
+	"go.viam.com/rdk/components/encoder"
 	"go.viam.com/rdk/components/generic"
+	"go.viam.com/rdk/components/motor"
+	"go.viam.com/rdk/components/powersensor"
 	"go.viam.com/rdk/components/sensor"
+	toggleswitch "go.viam.com/rdk/components/switch"
 	"go.viam.com/rdk/module"
 	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/services/discovery"
 )
 
 func main() {
@@ -14,5 +21,15 @@ func main() {
 		resource.APIModel{API: generic.API, Model: viammodbus.ModbusClientModel},
 		resource.APIModel{API: sensor.API, Model: viammodbus.ModbusSensorModel},
 		resource.APIModel{API: sensor.API, Model: viammodbus.CoilSensorModel},
+		resource.APIModel{API: sensor.API, Model: viammodbus.SunSpecSensorModel},
+		resource.APIModel{API: toggleswitch.API, Model: viammodbus.ModbusSwitchModel},
+		resource.APIModel{API: board.API, Model: viammodbus.ModbusBoardModel},
+		resource.APIModel{API: motor.API, Model: viammodbus.VFDMotorModel},
+		resource.APIModel{API: powersensor.API, Model: viammodbus.PowerSensorModel},
+		resource.APIModel{API: encoder.API, Model: viammodbus.ModbusEncoderModel},
+		resource.APIModel{API: button.API, Model: viammodbus.ModbusButtonModel},
+		resource.APIModel{API: generic.API, Model: viammodbus.ModbusServerModel},
+		resource.APIModel{API: generic.API, Model: viammodbus.ModbusGatewayModel},
+		resource.APIModel{API: discovery.API, Model: viammodbus.ModbusDiscoveryModel},
 	)
 }
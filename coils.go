@@ -2,7 +2,10 @@ package viammodbus
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
+	"sync"
 
 	"go.viam.com/rdk/components/sensor"
 	"go.viam.com/rdk/logging"
@@ -11,6 +14,13 @@ import (
 
 var CoilSensorModel = NamespaceFamily.WithModel("coils")
 
+const (
+	coilTypeCoil          = "coil"
+	coilTypeDiscreteInput = "discrete_input"
+	// Protocol maximum of bits per read request
+	maxCoilsPerRequest = 2000
+)
+
 func init() {
 	resource.RegisterComponent(
 		sensor.API,
@@ -20,26 +30,82 @@ func init() {
 		})
 }
 
-// TODO: Add the ability to read multiple coils at once
 type coilSensorConfig struct {
-	ModbusClient string `json:"modbus_client"`
-	Offset       uint16 `json:"offset"`
-	UnitID       uint8  `json:"unit_id"`
+	ModbusClient string       `json:"modbus_client"`
+	Offset       uint16       `json:"offset"`
+	UnitID       uint8        `json:"unit_id"`
+	Coils        []coilConfig `json:"coils"`
+	MaxGap       int          `json:"max_gap"`
+}
+
+// A named coil or discrete input, or a range of them if count is larger than one
+type coilConfig struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Offset int    `json:"offset"`
+	Count  int    `json:"count"`
 }
 
 func (cfg *coilSensorConfig) Validate(path string) ([]string, []string, error) {
 	if cfg.ModbusClient == "" {
 		return nil, nil, fmt.Errorf("modbus_client is required")
 	}
+	if cfg.UnitID > 247 {
+		return nil, nil, fmt.Errorf("unit_id must be between 1 and 247 or removed, got %d", cfg.UnitID)
+	}
+	if cfg.MaxGap < 0 {
+		return nil, nil, fmt.Errorf("max_gap must be non-negative, got %d", cfg.MaxGap)
+	}
+	names := map[string]bool{}
+	for i, c := range cfg.Coils {
+		if c.Name == "" {
+			return nil, nil, fmt.Errorf("name is required in coil %v", i)
+		}
+		if names[c.Name] {
+			return nil, nil, fmt.Errorf("name '%s' appears more than once in coils", c.Name)
+		}
+		names[c.Name] = true
+		if c.Type != "" && c.Type != coilTypeCoil && c.Type != coilTypeDiscreteInput {
+			return nil, nil, fmt.Errorf("type must be %v or %v in coil %v (%s), got %q", coilTypeCoil, coilTypeDiscreteInput, i, c.Name, c.Type)
+		}
+		if c.Offset < 0 || c.Offset > 65535 {
+			return nil, nil, fmt.Errorf("offset must be between 0 and 65535 in coil %v (%s), got %d", i, c.Name, c.Offset)
+		}
+		if c.Count < 0 || c.Count > maxCoilsPerRequest {
+			return nil, nil, fmt.Errorf("count must be between 1 and %d in coil %v (%s), got %d", maxCoilsPerRequest, i, c.Name, c.Count)
+		}
+		if c.Offset+max(c.Count, 1) > 65536 {
+			return nil, nil, fmt.Errorf("coil %v (%s) reaches beyond the last address 65535", i, c.Name)
+		}
+	}
 	return []string{cfg.ModbusClient}, nil, nil
 }
 
+// A single coil or discrete input and the readings key it is reported as
+type coilAddress struct {
+	key           string
+	offset        uint16
+	discreteInput bool
+}
+
+// A read request covering one or more configured addresses
+type coilRead struct {
+	discreteInput bool
+	offset        uint16
+	length        uint16
+	addresses     []coilAddress
+}
+
 type coilSensor struct {
 	resource.AlwaysRebuild
 	name   resource.Name
+	mu     sync.Mutex
 	logger logging.Logger
 	config *coilSensorConfig
 	client *modbusClient
+	unitID uint8
+	reads  []coilRead
+	coils  map[string]coilAddress
 }
 
 func newCoilSensor(ctx context.Context, deps resource.Dependencies, config resource.Config, logger logging.Logger) (sensor.Sensor, error) {
@@ -52,7 +118,26 @@ func newCoilSensor(ctx context.Context, deps resource.Dependencies, config resou
 		name:   config.ResourceName(),
 		logger: logger,
 		config: newConf,
+		unitID: 1,
+	}
+	if newConf.UnitID > 0 {
+		cs.unitID = newConf.UnitID
+	}
+
+	coils := newConf.Coils
+	if len(coils) == 0 {
+		// Single coil configuration of earlier versions
+		coils = []coilConfig{{Name: "coil", Offset: int(newConf.Offset)}}
+	}
+	addresses := expandCoils(coils)
+	cs.reads = planCoilReads(addresses, newConf.MaxGap)
+	cs.coils = map[string]coilAddress{}
+	for _, a := range addresses {
+		if !a.discreteInput {
+			cs.coils[a.key] = a
+		}
 	}
+	logger.Debugf("Reading %d coils and discrete inputs in %d requests", len(addresses), len(cs.reads))
 
 	// Get the modbus client from the global registry
 	client, err := GlobalClientRegistry.Get(newConf.ModbusClient)
@@ -64,25 +149,146 @@ func newCoilSensor(ctx context.Context, deps resource.Dependencies, config resou
 	return cs, nil
 }
 
+// Expands ranges into single addresses, ranges are reported as name_0, name_1, ...
+func expandCoils(coils []coilConfig) []coilAddress {
+	addresses := []coilAddress{}
+	for _, c := range coils {
+		discreteInput := c.Type == coilTypeDiscreteInput
+		if c.Count <= 1 {
+			addresses = append(addresses, coilAddress{key: c.Name, offset: uint16(c.Offset), discreteInput: discreteInput})
+			continue
+		}
+		for i := 0; i < c.Count; i++ {
+			addresses = append(addresses, coilAddress{
+				key:           c.Name + "_" + fmt.Sprint(i),
+				offset:        uint16(c.Offset + i),
+				discreteInput: discreteInput,
+			})
+		}
+	}
+	return addresses
+}
+
+// Groups the addresses into as few read requests as possible. Addresses of the same table
+// are read together if they are at most maxGap addresses apart.
+func planCoilReads(addresses []coilAddress, maxGap int) []coilRead {
+	sorted := make([]coilAddress, len(addresses))
+	copy(sorted, addresses)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].discreteInput != sorted[j].discreteInput {
+			return !sorted[i].discreteInput
+		}
+		return sorted[i].offset < sorted[j].offset
+	})
+
+	reads := []coilRead{}
+	for _, a := range sorted {
+		if n := len(reads); n > 0 {
+			last := &reads[n-1]
+			end := int(last.offset) + int(last.length)
+			if last.discreteInput == a.discreteInput && int(a.offset) <= end+maxGap && int(a.offset)-int(last.offset) < maxCoilsPerRequest {
+				last.length = max(last.length, a.offset-last.offset+1)
+				last.addresses = append(last.addresses, a)
+				continue
+			}
+		}
+		reads = append(reads, coilRead{discreteInput: a.discreteInput, offset: a.offset, length: 1, addresses: []coilAddress{a}})
+	}
+	return reads
+}
+
 func (cs *coilSensor) Readings(ctx context.Context, extra map[string]interface{}) (map[string]interface{}, error) {
-	return nil, fmt.Errorf("Coil component completed yet")
-	/*
-		value, err := cs.client.ReadCoil(cs.config.Offset, cs.config.UnitID)
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	readings := map[string]interface{}{}
+	for _, r := range cs.reads {
+		var values []bool
+		var err error
+		if r.discreteInput {
+			values, err = cs.client.ReadDiscreteInputs(r.offset, r.length, cs.unitID)
+		} else {
+			values, err = cs.client.ReadCoils(r.offset, r.length, cs.unitID)
+		}
 		if err != nil {
-			cs.logger.Errorf("Failed to read coil at offset %d: %v", cs.config.Offset, err)
+			cs.logger.Errorf("Failed to read %d coils at offset %d: %v", r.length, r.offset, err)
 			return nil, err
 		}
-
-		readings := map[string]interface{}{
-			"coil": value,
+		for _, a := range r.addresses {
+			i := int(a.offset - r.offset)
+			if i >= len(values) {
+				return nil, fmt.Errorf("short response reading coils at offset %d", r.offset)
+			}
+			readings[a.key] = values[i]
 		}
+	}
 
-		return readings, nil
-	*/
+	return readings, nil
 }
 
+// DoCommand supports {"set": {"<name>": true, "<range name>": [true, false, ...]}} to write coils
 func (cs *coilSensor) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
-	return nil, fmt.Errorf("DoCommand not implemented")
+	set, got := cmd["set"]
+	if !got {
+		return nil, fmt.Errorf("DoCommand not implemented")
+	}
+	values, ok := set.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("set must be an object of coil names and values")
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	for name, v := range values {
+		switch value := v.(type) {
+		case bool:
+			c, got := cs.coils[name]
+			if !got {
+				return nil, fmt.Errorf("unknown coil %q", name)
+			}
+			if err := cs.client.WriteCoil(c.offset, value, cs.unitID); err != nil {
+				return nil, err
+			}
+		case []interface{}:
+			c, err := cs.rangeStart(name, len(value))
+			if err != nil {
+				return nil, err
+			}
+			bools := make([]bool, len(value))
+			for i, b := range value {
+				if bools[i], ok = b.(bool); !ok {
+					return nil, fmt.Errorf("value %d of coil %q must be a boolean", i, name)
+				}
+			}
+			if err := cs.client.WriteCoils(c.offset, bools, cs.unitID); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("value of coil %q must be a boolean or a list of booleans", name)
+		}
+	}
+	return map[string]interface{}{"success": true}, nil
+}
+
+// Returns the first coil of a configured range and checks it has the given number of coils
+func (cs *coilSensor) rangeStart(name string, count int) (coilAddress, error) {
+	for _, c := range cs.config.Coils {
+		if c.Name != name {
+			continue
+		}
+		if c.Type == coilTypeDiscreteInput {
+			break
+		}
+		if max(c.Count, 1) != count {
+			return coilAddress{}, fmt.Errorf("coil range %q has %d coils, got %d values", name, max(c.Count, 1), count)
+		}
+		if c.Count <= 1 {
+			return cs.coils[name], nil
+		}
+		return cs.coils[name+"_0"], nil
+	}
+	return coilAddress{}, fmt.Errorf("unknown coil range %q", name)
 }
 
 func (cs *coilSensor) Close(ctx context.Context) error {
@@ -0,0 +1,232 @@
+package viammodbus
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Maps the columns of a vendor register map CSV to block fields by header name
+type CSVColumns struct {
+	Address string `json:"address"`
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Length  string `json:"length"`
+	Scale   string `json:"scale"`
+	Access  string `json:"access"`
+}
+
+var defaultCSVColumns = CSVColumns{
+	Address: "address",
+	Name:    "name",
+	Type:    "type",
+	Length:  "length",
+	Scale:   "scale",
+	Access:  "access",
+}
+
+// Vendor data type spellings mapped to block types
+var csvTypeAliases = map[string]string{
+	"bool":    "coils",
+	"coil":    "coils",
+	"u8":      "uint8",
+	"byte":    "uint8",
+	"u16":     "uint16",
+	"uint":    "uint16",
+	"word":    "uint16",
+	"s16":     "int16",
+	"i16":     "int16",
+	"int":     "int16",
+	"u32":     "uint32",
+	"udint":   "uint32",
+	"dword":   "uint32",
+	"s32":     "int32",
+	"i32":     "int32",
+	"dint":    "int32",
+	"float":   "float32",
+	"real":    "float32",
+	"f32":     "float32",
+	"double":  "float64",
+	"lreal":   "float64",
+	"f64":     "float64",
+	"string":  "bytes",
+	"ascii":   "bytes",
+	"holding": "holding_registers",
+	"input":   "input_registers",
+}
+
+// Reads a vendor register map CSV and turns every readable row into a block
+func LoadRegisterMapCSV(path string, columns *CSVColumns, delimiter string) ([]ModbusBlocks, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open register_map_csv: %w", err)
+	}
+	defer f.Close()
+	return parseRegisterMapCSV(f, withDefaultColumns(columns), delimiter)
+}
+
+func withDefaultColumns(columns *CSVColumns) CSVColumns {
+	merged := defaultCSVColumns
+	if columns == nil {
+		return merged
+	}
+	if columns.Address != "" {
+		merged.Address = columns.Address
+	}
+	if columns.Name != "" {
+		merged.Name = columns.Name
+	}
+	if columns.Type != "" {
+		merged.Type = columns.Type
+	}
+	if columns.Length != "" {
+		merged.Length = columns.Length
+	}
+	if columns.Scale != "" {
+		merged.Scale = columns.Scale
+	}
+	if columns.Access != "" {
+		merged.Access = columns.Access
+	}
+	return merged
+}
+
+func parseRegisterMapCSV(r io.Reader, columns CSVColumns, delimiter string) ([]ModbusBlocks, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	if delimiter != "" {
+		if len([]rune(delimiter)) != 1 {
+			return nil, fmt.Errorf("csv_delimiter must be a single character, got %q", delimiter)
+		}
+		reader.Comma = []rune(delimiter)[0]
+	}
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("register_map_csv: failed to read header: %w", err)
+	}
+	// Excel writes a byte order mark in front of UTF-8 CSV files
+	header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	index := map[string]int{}
+	for i, h := range header {
+		index[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	column := func(name string) int {
+		if i, got := index[strings.ToLower(name)]; got {
+			return i
+		}
+		return -1
+	}
+	addressCol, nameCol, typeCol := column(columns.Address), column(columns.Name), column(columns.Type)
+	lengthCol, scaleCol, accessCol := column(columns.Length), column(columns.Scale), column(columns.Access)
+	for _, required := range []struct {
+		col  int
+		name string
+	}{{addressCol, columns.Address}, {nameCol, columns.Name}, {typeCol, columns.Type}} {
+		if required.col < 0 {
+			return nil, fmt.Errorf("register_map_csv: column %q not found in header", required.name)
+		}
+	}
+
+	blocks := []ModbusBlocks{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("register_map_csv: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+		field := func(col int) string {
+			if col < 0 || col >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[col])
+		}
+		if isBlankRecord(record) {
+			continue
+		}
+		if access := strings.ToLower(field(accessCol)); access == "w" || access == "wo" || access == "write" {
+			// Write-only registers can't be read by the sensor
+			continue
+		}
+
+		block := ModbusBlocks{Name: field(nameCol)}
+		if block.Name == "" {
+			return nil, fmt.Errorf("register_map_csv line %d: %s is empty", line, columns.Name)
+		}
+		block.Offset, err = parseCSVAddress(field(addressCol))
+		if err != nil {
+			return nil, fmt.Errorf("register_map_csv line %d: invalid %s: %w", line, columns.Address, err)
+		}
+		block.Type = normalizeCSVType(field(typeCol))
+		if block.Type == "" {
+			return nil, fmt.Errorf("register_map_csv line %d: %s is empty", line, columns.Type)
+		}
+		if _, got := blockTypeWidths[block.Type]; !got {
+			return nil, fmt.Errorf("register_map_csv line %d: unsupported type %q", line, field(typeCol))
+		}
+		if s := field(lengthCol); s != "" {
+			block.Length, err = strconv.Atoi(s)
+			if err != nil {
+				return nil, fmt.Errorf("register_map_csv line %d: invalid %s %q", line, columns.Length, s)
+			}
+		} else if shouldCheckLength(block.Type) {
+			block.Length = 1
+		}
+		if limit := maxBlockLength(block.Type); shouldCheckLength(block.Type) && (block.Length <= 0 || block.Length > limit) {
+			return nil, fmt.Errorf("register_map_csv line %d: %s must be between 1 and %d for %s, got %d", line, columns.Length, limit, block.Type, block.Length)
+		}
+		if s := field(scaleCol); s != "" {
+			block.Scale, err = strconv.ParseFloat(s, 64)
+			if err != nil {
+				return nil, fmt.Errorf("register_map_csv line %d: invalid %s %q", line, columns.Scale, s)
+			}
+		}
+		blocks = append(blocks, block)
+	}
+	if len(blocks) == 0 {
+		return nil, errors.New("register_map_csv does not contain any readable rows")
+	}
+	return blocks, nil
+}
+
+// Accepts decimal and 0x prefixed hexadecimal addresses. Leading zeros are decimal, register
+// maps often pad addresses to a fixed width.
+func parseCSVAddress(s string) (int, error) {
+	if s == "" {
+		return 0, errors.New("address is empty")
+	}
+	digits, base := s, 10
+	if hex, got := strings.CutPrefix(strings.ToLower(s), "0x"); got {
+		digits, base = hex, 16
+	}
+	v, err := strconv.ParseInt(digits, base, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a number", s)
+	}
+	return int(v), nil
+}
+
+func normalizeCSVType(s string) string {
+	t := strings.ToLower(s)
+	if alias, got := csvTypeAliases[t]; got {
+		return alias
+	}
+	return t
+}
+
+func isBlankRecord(record []string) bool {
+	for _, v := range record {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,49 @@
+package viammodbus
+
+import (
+	"strings"
+	"testing"
+
+	"go.viam.com/test"
+)
+
+func TestParseRegisterMapCSV(t *testing.T) {
+	blocks, err := parseRegisterMapCSV(strings.NewReader(`address,name,type,length,scale,access
+0100,voltage,u16,,0.1,r
+0x10,energy,float,,,
+0009,serial,string,8,,ro
+200,setpoint,u16,,,w
+`), defaultCSVColumns, "")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, blocks, test.ShouldResemble, []ModbusBlocks{
+		{Name: "voltage", Offset: 100, Type: "uint16", Scale: 0.1},
+		{Name: "energy", Offset: 16, Type: "float32"},
+		{Name: "serial", Offset: 9, Type: "bytes", Length: 8},
+	})
+}
+
+func TestParseRegisterMapCSVByteOrderMark(t *testing.T) {
+	blocks, err := parseRegisterMapCSV(strings.NewReader("\ufeffAddress;Name;Type\r\n1;power;int\r\n"), defaultCSVColumns, ";")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, blocks, test.ShouldResemble, []ModbusBlocks{{Name: "power", Offset: 1, Type: "int16"}})
+}
+
+func TestParseRegisterMapCSVErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		csv   string
+		error string
+	}{
+		{"missing columns", "register,label\n1,a\n", `column "address" not found`},
+		{"unsupported type", "address,name,type\n1,a,u16\n2,b,bcd\n", `line 3: unsupported type "bcd"`},
+		{"length too long", "address,name,type,length\n1,a,holding,126\n", "line 2: length must be between 1 and 125"},
+		{"zero length", "address,name,type,length\n1,a,coil,0\n", "line 2: length must be between 1 and 2000"},
+		{"octal looking address", "address,name,type\n0o10,a,u16\n", "line 2: invalid address"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseRegisterMapCSV(strings.NewReader(tc.csv), defaultCSVColumns, "")
+			test.That(t, err, test.ShouldNotBeNil)
+			test.That(t, err.Error(), test.ShouldContainSubstring, tc.error)
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package viammodbus
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"go.viam.com/rdk/components/generic"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/rdk/services/discovery"
+	"go.viam.com/rdk/utils"
+)
+
+var ModbusDiscoveryModel = NamespaceFamily.WithModel("discovery")
+
+func init() {
+	resource.RegisterService(
+		discovery.API,
+		ModbusDiscoveryModel,
+		resource.Registration[discovery.Service, *discoveryConfig]{
+			Constructor: newModbusDiscovery,
+		})
+}
+
+// Serial devices probed unless serial_ports is configured
+var defaultSerialPorts = []string{
+	"/dev/ttyUSB*",
+	"/dev/ttyACM*",
+	"/dev/ttyAMA*",
+	"/dev/cu.usbserial*",
+	"/dev/cu.usbmodem*",
+}
+
+var (
+	defaultDiscoverySpeeds   = []uint{9600, 19200, 38400, 115200}
+	defaultDiscoveryParities = []string{"none", "even"}
+)
+
+type discoveryConfig struct {
+	SerialPorts  []string `json:"serial_ports"`
+	Speeds       []uint   `json:"speeds"`
+	Parities     []string `json:"parities"`
+	UnitIDs      []int    `json:"unit_ids"`
+	ProbeAddress int      `json:"probe_address"`
+	TCPHosts     []string `json:"tcp_hosts"`
+	Timeout      int      `json:"timeout_ms"`
+}
+
+func (cfg *discoveryConfig) Validate(path string) ([]string, []string, error) {
+	for _, pattern := range cfg.SerialPorts {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, nil, fmt.Errorf("invalid serial_ports pattern %q: %w", pattern, err)
+		}
+	}
+	for _, speed := range cfg.Speeds {
+		if speed == 0 {
+			return nil, nil, fmt.Errorf("speeds must be positive")
+		}
+	}
+	for _, parity := range cfg.Parities {
+		if _, err := GetParity(parity); err != nil {
+			return nil, nil, fmt.Errorf("parities must be %v, %v or %v, got %q", "none", "even", "odd", parity)
+		}
+	}
+	for _, id := range cfg.UnitIDs {
+		if id < 1 || id > 247 {
+			return nil, nil, fmt.Errorf("unit_ids must be between 1 and 247, got %d", id)
+		}
+	}
+	if cfg.ProbeAddress < 0 || cfg.ProbeAddress > 65535 {
+		return nil, nil, fmt.Errorf("probe_address must be between 0 and 65535, got %d", cfg.ProbeAddress)
+	}
+	for _, host := range cfg.TCPHosts {
+		if host == "" {
+			return nil, nil, fmt.Errorf("tcp_hosts must not contain empty hosts")
+		}
+	}
+	if cfg.Timeout < 0 {
+		return nil, nil, fmt.Errorf("timeout_ms must be non-negative, got %d", cfg.Timeout)
+	}
+	return nil, nil, nil
+}
+
+// Finds Modbus devices on the serial ports and tcp hosts and returns client configurations
+// for them
+type modbusDiscovery struct {
+	resource.AlwaysRebuild
+	resource.Named
+	logger logging.Logger
+
+	serialPorts  []string
+	speeds       []uint
+	parities     []string
+	unitIDs      []int
+	probeAddress uint16
+	tcpHosts     []string
+	timeout      time.Duration
+}
+
+func newModbusDiscovery(ctx context.Context, deps resource.Dependencies, conf resource.Config, logger logging.Logger) (discovery.Service, error) {
+	newConf, err := resource.NativeConfig[*discoveryConfig](conf)
+	if err != nil {
+		return nil, err
+	}
+	d := &modbusDiscovery{
+		Named:        conf.ResourceName().AsNamed(),
+		logger:       logger,
+		serialPorts:  newConf.SerialPorts,
+		speeds:       newConf.Speeds,
+		parities:     newConf.Parities,
+		unitIDs:      newConf.UnitIDs,
+		probeAddress: uint16(newConf.ProbeAddress),
+		tcpHosts:     newConf.TCPHosts,
+		timeout:      time.Duration(newConf.Timeout) * time.Millisecond,
+	}
+	if len(d.serialPorts) == 0 {
+		d.serialPorts = defaultSerialPorts
+	}
+	if len(d.speeds) == 0 {
+		d.speeds = defaultDiscoverySpeeds
+	}
+	if len(d.parities) == 0 {
+		d.parities = defaultDiscoveryParities
+	}
+	if len(d.unitIDs) == 0 {
+		d.unitIDs = []int{1}
+	}
+	return d, nil
+}
+
+func (d *modbusDiscovery) DiscoverResources(ctx context.Context, extra map[string]interface{}) ([]resource.Config, error) {
+	configs := []resource.Config{}
+
+	for _, port := range d.ports() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		url := "rtu://" + port
+		if GlobalClientRegistry.HasURL(url) {
+			d.logger.Debugf("Skipping %s, a client is connected to it", port)
+			continue
+		}
+		attributes, err := d.discoverSerial(ctx, url)
+		if err != nil {
+			return nil, err
+		}
+		if attributes != nil {
+			configs = append(configs, discoveredClient(port, attributes))
+		}
+	}
+
+	for _, host := range d.tcpHosts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if _, _, err := net.SplitHostPort(host); err != nil {
+			host = net.JoinHostPort(host, "502")
+		}
+		url := "tcp://" + host
+		for _, id := range d.unitIDs {
+			err := probeDevice(transportConfig{URL: url, Timeout: d.timeout}, uint8(id), d.probeAddress, d.logger)
+			if err != nil {
+				d.logger.Debugf("No response from unit id %d at %s: %v", id, host, err)
+				continue
+			}
+			d.logger.Infof("Found unit id %d at %s", id, host)
+			configs = append(configs, discoveredClient(host, utils.AttributeMap{"url": url}))
+			break
+		}
+	}
+	return configs, nil
+}
+
+// Returns the serial devices matching the configured patterns
+func (d *modbusDiscovery) ports() []string {
+	ports := []string{}
+	seen := map[string]bool{}
+	for _, pattern := range d.serialPorts {
+		matches, _ := filepath.Glob(pattern)
+		for _, port := range matches {
+			if !seen[port] {
+				seen[port] = true
+				ports = append(ports, port)
+			}
+		}
+	}
+	return ports
+}
+
+// Tries the line settings until a unit id answers, returns nil if none does
+func (d *modbusDiscovery) discoverSerial(ctx context.Context, url string) (utils.AttributeMap, error) {
+	for _, speed := range d.speeds {
+		for _, name := range d.parities {
+			parity, _ := GetParity(name)
+			for _, id := range d.unitIDs {
+				if err := ctx.Err(); err != nil {
+					return nil, err
+				}
+				err := probeDevice(transportConfig{
+					URL:     url,
+					Speed:   speed,
+					Parity:  parity,
+					Timeout: d.timeout,
+				}, uint8(id), d.probeAddress, d.logger)
+				if err != nil {
+					d.logger.Debugf("No response from unit id %d at %s %d %s: %v", id, url, speed, name, err)
+					continue
+				}
+				d.logger.Infof("Found unit id %d at %s with speed %d and parity %s", id, url, speed, name)
+				return utils.AttributeMap{"url": url, "speed": speed, "parity": name}, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+func discoveredClient(device string, attributes utils.AttributeMap) resource.Config {
+	return resource.Config{
+		Name:       "modbus-" + invalidNameChars.ReplaceAllString(filepath.Base(device), "-"),
+		API:        generic.API,
+		Model:      ModbusClientModel,
+		Attributes: attributes,
+	}
+}
+
+func (d *modbusDiscovery) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	return nil, fmt.Errorf("DoCommand not implemented")
+}
+
+func (d *modbusDiscovery) Close(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,189 @@
+package viammodbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"go.viam.com/rdk/components/encoder"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+)
+
+var ModbusEncoderModel = NamespaceFamily.WithModel("encoder")
+
+func init() {
+	resource.RegisterComponent(
+		encoder.API,
+		ModbusEncoderModel,
+		resource.Registration[encoder.Encoder, *encoderConfig]{
+			Constructor: newModbusEncoder,
+		})
+}
+
+type encoderConfig struct {
+	ModbusClient     string       `json:"modbus_connection_name"`
+	UnitID           int          `json:"unit_id"`
+	Counter          *pointConfig `json:"counter"`
+	Reset            *pointConfig `json:"reset"`
+	ResetValue       int          `json:"reset_value"`
+	TicksPerRotation int          `json:"ticks_per_rotation"`
+}
+
+func (cfg *encoderConfig) Validate(path string) ([]string, []string, error) {
+	if cfg.ModbusClient == "" {
+		return nil, nil, resource.NewConfigValidationFieldRequiredError(path, "modbus_connection_name")
+	}
+	if _, err := unitIDOrDefault(cfg.UnitID); err != nil {
+		return nil, nil, err
+	}
+	if cfg.Counter == nil {
+		return nil, nil, resource.NewConfigValidationFieldRequiredError(path, "counter")
+	}
+	counter := *cfg.Counter
+	if counter.DataType == "" {
+		counter.DataType = "uint32"
+	}
+	if counter.DataType != "uint32" && counter.DataType != "int32" {
+		return nil, nil, fmt.Errorf("counter data_type must be uint32 or int32, got %q", counter.DataType)
+	}
+	if err := counter.validate("counter", false); err != nil {
+		return nil, nil, err
+	}
+	if cfg.Counter.isBit() {
+		return nil, nil, errors.New("counter must be a holding or input register")
+	}
+	if cfg.Reset != nil {
+		if err := cfg.Reset.validate("reset", true); err != nil {
+			return nil, nil, err
+		}
+	}
+	if cfg.TicksPerRotation < 0 {
+		return nil, nil, fmt.Errorf("ticks_per_rotation must be non-negative, got %d", cfg.TicksPerRotation)
+	}
+	return []string{cfg.ModbusClient}, nil, nil
+}
+
+type modbusEncoder struct {
+	resource.AlwaysRebuild
+	resource.Named
+	mu     sync.Mutex
+	logger logging.Logger
+	mc     *modbusClient
+	unitID uint8
+	cfg    *encoderConfig
+
+	// Position accumulated across counter wrap-arounds
+	position int64
+	lastRaw  uint32
+	hasLast  bool
+}
+
+func newModbusEncoder(ctx context.Context, deps resource.Dependencies, conf resource.Config, logger logging.Logger) (encoder.Encoder, error) {
+	newConf, err := resource.NativeConfig[*encoderConfig](conf)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &modbusEncoder{
+		Named:  conf.ResourceName().AsNamed(),
+		logger: logger,
+		cfg:    newConf,
+	}
+	e.unitID, err = unitIDOrDefault(newConf.UnitID)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := GlobalClientRegistry.Get(newConf.ModbusClient)
+	if err != nil {
+		return nil, err
+	}
+	e.mc = client
+	return e, nil
+}
+
+// Reads the counter and accumulates the change since the last read. The difference is taken
+// modulo 2^32, so the position keeps counting when the counter wraps around.
+func (e *modbusEncoder) update() (int64, error) {
+	raw, err := e.mc.ReadUInt32(uint16(e.cfg.Counter.Offset), e.cfg.Counter.regType(), e.unitID)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case !e.hasLast && e.cfg.Counter.DataType == "int32":
+		e.position = int64(int32(raw))
+	case !e.hasLast:
+		e.position = int64(raw)
+	default:
+		e.position += int64(int32(raw - e.lastRaw))
+	}
+	e.lastRaw = raw
+	e.hasLast = true
+	return e.position, nil
+}
+
+func (e *modbusEncoder) Position(ctx context.Context, positionType encoder.PositionType, extra map[string]interface{}) (float64, encoder.PositionType, error) {
+	if positionType == encoder.PositionTypeDegrees && e.cfg.TicksPerRotation == 0 {
+		return 0, encoder.PositionTypeUnspecified, encoder.NewPositionTypeUnsupportedError(positionType)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	ticks, err := e.update()
+	if err != nil {
+		return 0, encoder.PositionTypeUnspecified, err
+	}
+
+	if positionType == encoder.PositionTypeDegrees {
+		tpr := int64(e.cfg.TicksPerRotation)
+		angle := float64(((ticks%tpr)+tpr)%tpr) / float64(tpr) * 360
+		return angle, encoder.PositionTypeDegrees, nil
+	}
+	return float64(ticks), encoder.PositionTypeTicks, nil
+}
+
+// Writes the reset coil or register if configured, then the current count becomes zero
+func (e *modbusEncoder) ResetPosition(ctx context.Context, extra map[string]interface{}) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.cfg.Reset != nil {
+		value := float64(e.cfg.ResetValue)
+		if e.cfg.Reset.Type == pointCoil {
+			value = 1
+		}
+		if err := e.cfg.Reset.writeRaw(e.mc, e.unitID, value); err != nil {
+			return err
+		}
+		if e.cfg.Reset.Type == pointCoil {
+			// Release the reset coil so the next reset is a rising edge again
+			if err := e.cfg.Reset.writeRaw(e.mc, e.unitID, 0); err != nil {
+				return err
+			}
+		}
+	}
+	// The counter may not restart from zero, or may have counted on since the reset, so the
+	// value read back is the baseline of the next position
+	if _, err := e.update(); err != nil {
+		return err
+	}
+	e.position = 0
+	return nil
+}
+
+func (e *modbusEncoder) Properties(ctx context.Context, extra map[string]interface{}) (encoder.Properties, error) {
+	return encoder.Properties{
+		TicksCountSupported:   true,
+		AngleDegreesSupported: e.cfg.TicksPerRotation > 0,
+	}, nil
+}
+
+func (e *modbusEncoder) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	return nil, fmt.Errorf("DoCommand not implemented")
+}
+
+func (e *modbusEncoder) Close(ctx context.Context) error {
+	return nil
+}
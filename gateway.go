@@ -0,0 +1,402 @@
+package viammodbus
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/simonvetter/modbus"
+	"go.viam.com/rdk/components/generic"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+)
+
+var ModbusGatewayModel = NamespaceFamily.WithModel("gateway")
+
+func init() {
+	resource.RegisterComponent(
+		generic.API,
+		ModbusGatewayModel,
+		resource.Registration[generic.Resource, *gatewayConfig]{
+			Constructor: newModbusGateway,
+		})
+}
+
+type gatewayConfig struct {
+	URL        string               `json:"url"`
+	Timeout    int                  `json:"timeout_ms"`
+	MaxClients uint                 `json:"max_clients"`
+	Routes     []gatewayRouteConfig `json:"routes"`
+}
+
+// Forwards the requests for a unit id to a Modbus client
+type gatewayRouteConfig struct {
+	UnitID       int    `json:"unit_id"`
+	ModbusClient string `json:"modbus_connection_name"`
+	TargetUnitID int    `json:"target_unit_id"`
+}
+
+func (cfg *gatewayConfig) Validate(path string) ([]string, []string, error) {
+	if cfg.URL != "" {
+		u, err := url.Parse(cfg.URL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid url %q: %w", cfg.URL, err)
+		}
+		if u.Scheme != "tcp" {
+			return nil, nil, fmt.Errorf("url scheme must be tcp, the modbus library only provides a tcp server, got %q", u.Scheme)
+		}
+	}
+	if cfg.Timeout < 0 {
+		return nil, nil, fmt.Errorf("timeout_ms must be non-negative, got %d", cfg.Timeout)
+	}
+	if len(cfg.Routes) == 0 {
+		return nil, nil, resource.NewConfigValidationFieldRequiredError(path, "routes")
+	}
+
+	deps := []string{}
+	clients := map[string]bool{}
+	unitIDs := map[int]bool{}
+	for i, r := range cfg.Routes {
+		if r.ModbusClient == "" {
+			return nil, nil, fmt.Errorf("modbus_connection_name is required in route %v", i)
+		}
+		if r.UnitID < 0 || r.UnitID > 255 {
+			return nil, nil, fmt.Errorf("unit_id must be between 0 and 255 in route %v, got %d", i, r.UnitID)
+		}
+		if unitIDs[r.UnitID] {
+			return nil, nil, fmt.Errorf("unit_id %d is routed more than once", r.UnitID)
+		}
+		unitIDs[r.UnitID] = true
+		if r.TargetUnitID == 0 && (r.UnitID < 1 || r.UnitID > 247) {
+			return nil, nil, fmt.Errorf("target_unit_id is required in route %v for unit_id %d", i, r.UnitID)
+		}
+		if r.TargetUnitID < 0 || r.TargetUnitID > 247 {
+			return nil, nil, fmt.Errorf("target_unit_id must be between 1 and 247 in route %v, got %d", i, r.TargetUnitID)
+		}
+		if !clients[r.ModbusClient] {
+			clients[r.ModbusClient] = true
+			deps = append(deps, r.ModbusClient)
+		}
+	}
+	return deps, nil, nil
+}
+
+type gatewayRoute struct {
+	mc     *modbusClient
+	unitID uint8
+}
+
+type modbusGateway struct {
+	resource.AlwaysRebuild
+	resource.Named
+	logger   logging.Logger
+	server   *modbus.ModbusServer
+	frontend *gatewayFrontend
+	routes   map[uint8]gatewayRoute
+}
+
+func newModbusGateway(ctx context.Context, deps resource.Dependencies, conf resource.Config, logger logging.Logger) (generic.Resource, error) {
+	newConf, err := resource.NativeConfig[*gatewayConfig](conf)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &modbusGateway{
+		Named:  conf.ResourceName().AsNamed(),
+		logger: logger,
+		routes: map[uint8]gatewayRoute{},
+	}
+	for _, r := range newConf.Routes {
+		client, err := GlobalClientRegistry.Get(r.ModbusClient)
+		if err != nil {
+			return nil, err
+		}
+		target := r.TargetUnitID
+		if target == 0 {
+			target = r.UnitID
+		}
+		g.routes[uint8(r.UnitID)] = gatewayRoute{mc: client, unitID: uint8(target)}
+	}
+
+	serverURL := newConf.URL
+	if serverURL == "" {
+		serverURL = defaultServerURL
+	}
+	u, err := url.Parse(serverURL)
+	if err != nil {
+		return nil, err
+	}
+	timeout := time.Duration(newConf.Timeout) * time.Millisecond
+	if timeout == 0 {
+		timeout = defaultGatewayTimeout
+	}
+
+	// The library server listens on the loopback interface behind the frontend
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	upstream := listener.Addr().String()
+	listener.Close()
+	g.server, err = modbus.NewServer(&modbus.ServerConfiguration{
+		URL: "tcp://" + upstream,
+		// Outlasts the idle timeout of the frontend, which closes idle connections first
+		Timeout:    timeout + time.Second,
+		MaxClients: newConf.MaxClients,
+	}, g)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.server.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start modbus gateway: %w", err)
+	}
+	g.frontend, err = newGatewayFrontend(u.Host, upstream, timeout, logger)
+	if err != nil {
+		g.server.Stop()
+		return nil, fmt.Errorf("failed to start modbus gateway on %s: %w", serverURL, err)
+	}
+	logger.Infof("Modbus gateway listening on %s", serverURL)
+	return g, nil
+}
+
+// Sends the request over the client of the route. Exceptions of the device are passed back to
+// the master, any other failure is answered with a gateway target failed to respond exception.
+func (g *modbusGateway) forward(unitID uint8, r func(route gatewayRoute) error) error {
+	route, got := g.routes[unitID]
+	if !got {
+		return modbus.ErrGWPathUnavailable
+	}
+	err := route.mc.request(func() error { return r(route) }, route.unitID)
+	if err != nil && !isModbusException(err) {
+		g.logger.Debugf("Failed to forward request for unit id %d: %v", unitID, err)
+		return modbus.ErrGWTargetFailedToRespond
+	}
+	return err
+}
+
+func (g *modbusGateway) HandleCoils(req *modbus.CoilsRequest) (res []bool, err error) {
+	err = g.forward(req.UnitId, func(route gatewayRoute) error {
+		c := route.mc.client
+		switch {
+		case !req.IsWrite:
+			res, err = c.ReadCoils(req.Addr, req.Quantity)
+			return err
+		case len(req.Args) == 1 && g.frontend.functionCode(req.ClientAddr) == 0x05:
+			return c.WriteCoil(req.Addr, req.Args[0])
+		default:
+			return c.WriteCoils(req.Addr, req.Args)
+		}
+	})
+	return res, err
+}
+
+func (g *modbusGateway) HandleDiscreteInputs(req *modbus.DiscreteInputsRequest) (res []bool, err error) {
+	err = g.forward(req.UnitId, func(route gatewayRoute) error {
+		res, err = route.mc.client.ReadDiscreteInputs(req.Addr, req.Quantity)
+		return err
+	})
+	return res, err
+}
+
+func (g *modbusGateway) HandleHoldingRegisters(req *modbus.HoldingRegistersRequest) (res []uint16, err error) {
+	err = g.forward(req.UnitId, func(route gatewayRoute) error {
+		if !req.IsWrite {
+			res, err = readRawRegisters(route.mc.client, req.Addr, req.Quantity, modbus.HOLDING_REGISTER)
+			return err
+		}
+		if len(req.Args) == 1 && g.frontend.functionCode(req.ClientAddr) == 0x06 {
+			value := req.Args[0]
+			if route.mc.swapsBytes() {
+				// Undo the byte swap of the client so the register is written as received
+				value = value<<8 | value>>8
+			}
+			return route.mc.client.WriteRegister(req.Addr, value)
+		}
+		payload := make([]byte, 2*len(req.Args))
+		for i, v := range req.Args {
+			binary.BigEndian.PutUint16(payload[2*i:], v)
+		}
+		return route.mc.client.WriteRawBytes(req.Addr, payload)
+	})
+	return res, err
+}
+
+func (g *modbusGateway) HandleInputRegisters(req *modbus.InputRegistersRequest) (res []uint16, err error) {
+	err = g.forward(req.UnitId, func(route gatewayRoute) error {
+		res, err = readRawRegisters(route.mc.client, req.Addr, req.Quantity, modbus.INPUT_REGISTER)
+		return err
+	})
+	return res, err
+}
+
+// Reads registers as they come off the wire, ignoring the byte order configured on the client
+func readRawRegisters(c *modbus.ModbusClient, addr, quantity uint16, regType modbus.RegType) ([]uint16, error) {
+	b, err := c.ReadRawBytes(addr, 2*quantity, regType)
+	if err != nil {
+		return nil, err
+	}
+	regs := make([]uint16, quantity)
+	for i := range regs {
+		regs[i] = binary.BigEndian.Uint16(b[2*i:])
+	}
+	return regs, nil
+}
+
+func (g *modbusGateway) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	return nil, fmt.Errorf("DoCommand not implemented")
+}
+
+func (g *modbusGateway) Close(ctx context.Context) error {
+	return errors.Join(g.frontend.Close(), g.server.Stop())
+}
+
+// Idle timeout of the library server when timeout_ms is not set
+const defaultGatewayTimeout = 120 * time.Second
+
+// Listener the masters connect to, it relays each connection to the library server and
+// records the function code of every request. The library passes a single write and a
+// multiple write of one coil or register to the handler alike, the gateway needs the function
+// code to forward the write as the master sent it.
+type gatewayFrontend struct {
+	logger   logging.Logger
+	listener net.Listener
+	upstream string
+	timeout  time.Duration
+
+	mu     sync.Mutex
+	closed bool
+	conns  map[net.Conn]bool
+	// Function code of the request in flight, by the address the library server sees as the
+	// client address
+	functionCodes map[string]byte
+	wg            sync.WaitGroup
+}
+
+func newGatewayFrontend(addr, upstream string, timeout time.Duration, logger logging.Logger) (*gatewayFrontend, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	f := &gatewayFrontend{
+		logger:        logger,
+		listener:      listener,
+		upstream:      upstream,
+		timeout:       timeout,
+		conns:         map[net.Conn]bool{},
+		functionCodes: map[string]byte{},
+	}
+	f.wg.Add(1)
+	go f.accept()
+	return f, nil
+}
+
+func (f *gatewayFrontend) accept() {
+	defer f.wg.Done()
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		f.wg.Add(1)
+		go f.serve(conn)
+	}
+}
+
+// Relays the requests of a master one at a time, so the recorded function code is the one of
+// the request the library server is handling
+func (f *gatewayFrontend) serve(conn net.Conn) {
+	defer f.wg.Done()
+	upstream, err := net.Dial("tcp", f.upstream)
+	if err != nil {
+		f.logger.Debugf("Closing connection of %v: %v", conn.RemoteAddr(), err)
+		conn.Close()
+		return
+	}
+	key := upstream.LocalAddr().String()
+	f.mu.Lock()
+	if f.closed {
+		f.mu.Unlock()
+		conn.Close()
+		upstream.Close()
+		return
+	}
+	f.conns[conn] = true
+	f.conns[upstream] = true
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		delete(f.conns, conn)
+		delete(f.conns, upstream)
+		delete(f.functionCodes, key)
+		f.mu.Unlock()
+		conn.Close()
+		upstream.Close()
+	}()
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(f.timeout))
+		req, err := readMBAPFrame(conn)
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.functionCodes[key] = req[7]
+		f.mu.Unlock()
+		if _, err := upstream.Write(req); err != nil {
+			return
+		}
+		// The library server answers every request or closes the connection
+		res, err := readMBAPFrame(upstream)
+		if err != nil {
+			return
+		}
+		if _, err := conn.Write(res); err != nil {
+			return
+		}
+	}
+}
+
+// Returns the function code of the request the library server handles for a client address
+func (f *gatewayFrontend) functionCode(clientAddr string) byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.functionCodes[clientAddr]
+}
+
+func (f *gatewayFrontend) Close() error {
+	err := f.listener.Close()
+	f.mu.Lock()
+	f.closed = true
+	for conn := range f.conns {
+		conn.Close()
+	}
+	f.mu.Unlock()
+	f.wg.Wait()
+	if errors.Is(err, net.ErrClosed) {
+		err = nil
+	}
+	return err
+}
+
+// Reads a Modbus TCP frame, header included
+func readMBAPFrame(r io.Reader) ([]byte, error) {
+	frame := make([]byte, 7, 260)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint16(frame[4:6])
+	if length < 2 || length > 254 {
+		return nil, fmt.Errorf("invalid MBAP length %d", length)
+	}
+	frame = frame[:6+length]
+	if _, err := io.ReadFull(r, frame[7:]); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
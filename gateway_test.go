@@ -0,0 +1,83 @@
+package viammodbus
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/simonvetter/modbus"
+	"go.viam.com/rdk/components/generic"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+	"go.viam.com/test"
+)
+
+// Returns a free address on the loopback interface
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	test.That(t, err, test.ShouldBeNil)
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+func TestGatewayKeepsFunctionCode(t *testing.T) {
+	// Device behind the gateway that records the function codes of the writes it receives
+	var mu sync.Mutex
+	functionCodes := []byte{}
+	device, err := net.Listen("tcp", "127.0.0.1:0")
+	test.That(t, err, test.ShouldBeNil)
+	t.Cleanup(func() { device.Close() })
+	go func() {
+		for {
+			conn, err := device.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				for {
+					req, err := readMBAPFrame(conn)
+					if err != nil {
+						return
+					}
+					mu.Lock()
+					functionCodes = append(functionCodes, req[7])
+					mu.Unlock()
+					// Single and multiple writes both echo the first four bytes of the request
+					res := append([]byte{}, req[:12]...)
+					res[5] = 6
+					conn.Write(res)
+				}
+			}()
+		}
+	}()
+	newTestClient(t, "tcp://"+device.Addr().String())
+
+	addr := freeAddr(t)
+	cfg := &gatewayConfig{URL: "tcp://" + addr, Routes: []gatewayRouteConfig{{UnitID: 1, ModbusClient: t.Name()}}}
+	_, _, err = cfg.Validate("")
+	test.That(t, err, test.ShouldBeNil)
+	g, err := newModbusGateway(context.Background(), nil, resource.Config{
+		Name:                "gateway",
+		API:                 generic.API,
+		ConvertedAttributes: cfg,
+	}, logging.NewTestLogger(t))
+	test.That(t, err, test.ShouldBeNil)
+	t.Cleanup(func() { g.Close(context.Background()) })
+
+	master, err := modbus.NewClient(&modbus.ClientConfiguration{URL: "tcp://" + addr})
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, master.Open(), test.ShouldBeNil)
+	defer master.Close()
+
+	test.That(t, master.WriteRegisters(0, []uint16{7}), test.ShouldBeNil)
+	test.That(t, master.WriteRegister(1, 8), test.ShouldBeNil)
+	test.That(t, master.WriteCoils(2, []bool{true}), test.ShouldBeNil)
+	test.That(t, master.WriteCoil(3, true), test.ShouldBeNil)
+
+	mu.Lock()
+	defer mu.Unlock()
+	test.That(t, functionCodes, test.ShouldResemble, []byte{0x10, 0x06, 0x0F, 0x05})
+}
@@ -5,8 +5,12 @@ go 1.24.4
 toolchain go1.24.5
 
 require (
+	github.com/goburrow/serial v0.1.0
 	github.com/simonvetter/modbus v1.6.3
+	go.viam.com/api v0.1.458
 	go.viam.com/rdk v0.85.0
+	go.viam.com/test v1.2.4
+	golang.org/x/sys v0.34.0
 )
 
 require (
@@ -63,7 +67,6 @@ require (
 	github.com/go-logr/logr v1.4.3 // indirect
 	github.com/go-logr/stdr v1.2.2 // indirect
 	github.com/go-viper/mapstructure/v2 v2.4.0 // indirect
-	github.com/goburrow/serial v0.1.0 // indirect
 	github.com/goccy/go-json v0.10.5 // indirect
 	github.com/gogo/protobuf v1.3.2 // indirect
 	github.com/golang-jwt/jwt/v4 v4.5.2 // indirect
@@ -159,8 +162,6 @@ require (
 	go.uber.org/goleak v1.3.0 // indirect
 	go.uber.org/multierr v1.11.0 // indirect
 	go.uber.org/zap v1.27.0 // indirect
-	go.viam.com/api v0.1.458 // indirect
-	go.viam.com/test v1.2.4 // indirect
 	go.viam.com/utils v0.1.153 // indirect
 	go4.org/unsafe/assume-no-moving-gc v0.0.0-20230525183740-e7c30c78aeb2 // indirect
 	golang.org/x/crypto v0.40.0 // indirect
@@ -170,7 +171,6 @@ require (
 	golang.org/x/net v0.42.0 // indirect
 	golang.org/x/oauth2 v0.30.0 // indirect
 	golang.org/x/sync v0.16.0 // indirect
-	golang.org/x/sys v0.34.0 // indirect
 	golang.org/x/text v0.27.0 // indirect
 	golang.org/x/time v0.12.0 // indirect
 	golang.org/x/tools v0.35.0 // indirect
@@ -0,0 +1,207 @@
+package viammodbus
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/simonvetter/modbus"
+)
+
+// Tables a point can refer to
+const (
+	pointCoil            = "coil"
+	pointDiscreteInput   = "discrete_input"
+	pointHoldingRegister = "holding_register"
+	pointInputRegister   = "input_register"
+)
+
+// A single coil, discrete input or register value used by the component models
+type pointConfig struct {
+	Type     string  `json:"type"`
+	Offset   int     `json:"offset"`
+	DataType string  `json:"data_type"`
+	Scale    float64 `json:"scale"`
+}
+
+// Validates the point, writable requires a coil or holding register
+func (p *pointConfig) validate(name string, writable bool) error {
+	switch p.Type {
+	case pointCoil, pointHoldingRegister:
+	case pointDiscreteInput, pointInputRegister:
+		if writable {
+			return fmt.Errorf("%s must be a %v or %v to be written, got %v", name, pointCoil, pointHoldingRegister, p.Type)
+		}
+	default:
+		return fmt.Errorf("%s type must be one of %v, %v, %v or %v, got %q", name, pointCoil, pointDiscreteInput, pointHoldingRegister, pointInputRegister, p.Type)
+	}
+	if p.Offset < 0 || p.Offset > 65535 {
+		return fmt.Errorf("%s offset must be between 0 and 65535, got %d", name, p.Offset)
+	}
+	if p.isBit() {
+		if p.DataType != "" {
+			return fmt.Errorf("%s data_type is only supported for registers", name)
+		}
+		return nil
+	}
+	switch p.DataType {
+	case "", "uint16", "int16", "uint32", "int32", "float32":
+	default:
+		return fmt.Errorf("%s data_type must be one of uint16, int16, uint32, int32 or float32, got %q", name, p.DataType)
+	}
+	if p.Offset+p.width() > 65536 {
+		return fmt.Errorf("%s reaches beyond the last address 65535", name)
+	}
+	return nil
+}
+
+func (p *pointConfig) isBit() bool {
+	return p.Type == pointCoil || p.Type == pointDiscreteInput
+}
+
+// Number of registers the point occupies
+func (p *pointConfig) width() int {
+	switch p.DataType {
+	case "uint32", "int32", "float32":
+		return 2
+	default:
+		return 1
+	}
+}
+
+func (p *pointConfig) regType() modbus.RegType {
+	if p.Type == pointInputRegister {
+		return modbus.INPUT_REGISTER
+	}
+	return modbus.HOLDING_REGISTER
+}
+
+func (p *pointConfig) scale() float64 {
+	if p.Scale == 0 {
+		return 1
+	}
+	return p.Scale
+}
+
+// Reads the raw register value or 0 and 1 for coils and discrete inputs
+func (p *pointConfig) readRaw(mc *modbusClient, unitID uint8) (float64, error) {
+	offset := uint16(p.Offset)
+	switch p.Type {
+	case pointCoil:
+		b, err := mc.ReadCoil(offset, unitID)
+		return boolToFloat(b), err
+	case pointDiscreteInput:
+		b, err := mc.ReadDiscreteInput(offset, unitID)
+		return boolToFloat(b), err
+	}
+	switch p.DataType {
+	case "int16":
+		v, err := mc.ReadInt16(offset, p.regType(), unitID)
+		return float64(v), err
+	case "uint32":
+		v, err := mc.ReadUInt32(offset, p.regType(), unitID)
+		return float64(v), err
+	case "int32":
+		v, err := mc.ReadInt32(offset, p.regType(), unitID)
+		return float64(v), err
+	case "float32":
+		v, err := mc.ReadFloat32(offset, p.regType(), unitID)
+		return float64(v), err
+	default:
+		v, err := mc.ReadUInt16(offset, p.regType(), unitID)
+		return float64(v), err
+	}
+}
+
+// Reads the value with the scale applied
+func (p *pointConfig) read(mc *modbusClient, unitID uint8) (float64, error) {
+	v, err := p.readRaw(mc, unitID)
+	if err != nil {
+		return 0, err
+	}
+	if p.isBit() {
+		return v, nil
+	}
+	return v * p.scale(), nil
+}
+
+func (p *pointConfig) readBool(mc *modbusClient, unitID uint8) (bool, error) {
+	v, err := p.readRaw(mc, unitID)
+	return v != 0, err
+}
+
+// Writes the raw value, coils are set for any non-zero value
+func (p *pointConfig) writeRaw(mc *modbusClient, unitID uint8, value float64) error {
+	offset := uint16(p.Offset)
+	if p.Type == pointCoil {
+		return mc.WriteCoil(offset, value != 0, unitID)
+	}
+	if p.Type != pointHoldingRegister {
+		return fmt.Errorf("%v at offset %d is read-only", p.Type, p.Offset)
+	}
+	bits, err := registerBits(p.DataType, value)
+	if err != nil {
+		return err
+	}
+	switch p.DataType {
+	case "float32":
+		return mc.WriteFloat32(offset, float32(value), unitID)
+	case "uint32", "int32":
+		return mc.WriteUInt32(offset, bits, unitID)
+	default:
+		return mc.WriteUInt16(offset, uint16(bits), unitID)
+	}
+}
+
+// Encodes a raw value as the register bits of the data type, 16 bit types use the low word
+func registerBits(dataType string, value float64) (uint32, error) {
+	switch dataType {
+	case "float32":
+		return math.Float32bits(float32(value)), nil
+	case "uint32":
+		if value < 0 || value > math.MaxUint32 {
+			return 0, fmt.Errorf("value %v out of range for uint32", value)
+		}
+		return uint32(math.Round(value)), nil
+	case "int32":
+		if value < math.MinInt32 || value > math.MaxInt32 {
+			return 0, fmt.Errorf("value %v out of range for int32", value)
+		}
+		return uint32(int32(math.Round(value))), nil
+	case "int16":
+		if value < math.MinInt16 || value > math.MaxInt16 {
+			return 0, fmt.Errorf("value %v out of range for int16", value)
+		}
+		return uint32(uint16(int16(math.Round(value)))), nil
+	default:
+		if value < 0 || value > math.MaxUint16 {
+			return 0, fmt.Errorf("value %v out of range for uint16", value)
+		}
+		return uint32(math.Round(value)), nil
+	}
+}
+
+// Writes the value with the scale removed
+func (p *pointConfig) write(mc *modbusClient, unitID uint8, value float64) error {
+	if p.isBit() {
+		return p.writeRaw(mc, unitID, value)
+	}
+	return p.writeRaw(mc, unitID, value/p.scale())
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Validates an optional unit id and returns the id to use
+func unitIDOrDefault(unitID int) (uint8, error) {
+	if unitID == 0 {
+		return 1, nil
+	}
+	if unitID < 1 || unitID > 247 {
+		return 0, fmt.Errorf("unit_id must be between 1 and 247 or removed, got %d", unitID)
+	}
+	return uint8(unitID), nil
+}
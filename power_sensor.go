@@ -0,0 +1,175 @@
+package viammodbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"go.viam.com/rdk/components/powersensor"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+)
+
+var PowerSensorModel = NamespaceFamily.WithModel("power-sensor")
+
+func init() {
+	resource.RegisterComponent(
+		powersensor.API,
+		PowerSensorModel,
+		resource.Registration[powersensor.PowerSensor, *powerSensorConfig]{
+			Constructor: newPowerSensor,
+		})
+}
+
+type powerSensorConfig struct {
+	ModbusClient string         `json:"modbus_connection_name"`
+	UnitID       int            `json:"unit_id"`
+	Voltage      *powerQuantity `json:"voltage"`
+	Current      *powerQuantity `json:"current"`
+	Power        *powerQuantity `json:"power"`
+}
+
+// A register holding a measured quantity
+type powerQuantity struct {
+	Type     string  `json:"type"`
+	Offset   int     `json:"offset"`
+	DataType string  `json:"data_type"`
+	Scale    float64 `json:"scale"`
+	AC       bool    `json:"ac"`
+}
+
+func (q *powerQuantity) point() *pointConfig {
+	return &pointConfig{Type: q.Type, Offset: q.Offset, DataType: q.DataType, Scale: q.Scale}
+}
+
+func (cfg *powerSensorConfig) Validate(path string) ([]string, []string, error) {
+	if cfg.ModbusClient == "" {
+		return nil, nil, resource.NewConfigValidationFieldRequiredError(path, "modbus_connection_name")
+	}
+	if _, err := unitIDOrDefault(cfg.UnitID); err != nil {
+		return nil, nil, err
+	}
+	if cfg.Voltage == nil && cfg.Current == nil && cfg.Power == nil {
+		return nil, nil, errors.New("at least one of voltage, current or power is required")
+	}
+	for name, q := range map[string]*powerQuantity{"voltage": cfg.Voltage, "current": cfg.Current, "power": cfg.Power} {
+		if q == nil {
+			continue
+		}
+		if q.Type != pointHoldingRegister && q.Type != pointInputRegister {
+			return nil, nil, fmt.Errorf("%s type must be %v or %v, got %q", name, pointHoldingRegister, pointInputRegister, q.Type)
+		}
+		if err := q.point().validate(name, false); err != nil {
+			return nil, nil, err
+		}
+	}
+	return []string{cfg.ModbusClient}, nil, nil
+}
+
+type powerSensor struct {
+	resource.AlwaysRebuild
+	resource.Named
+	mu      sync.Mutex
+	logger  logging.Logger
+	mc      *modbusClient
+	unitID  uint8
+	voltage *powerQuantity
+	current *powerQuantity
+	power   *powerQuantity
+}
+
+func newPowerSensor(ctx context.Context, deps resource.Dependencies, conf resource.Config, logger logging.Logger) (powersensor.PowerSensor, error) {
+	newConf, err := resource.NativeConfig[*powerSensorConfig](conf)
+	if err != nil {
+		return nil, err
+	}
+
+	ps := &powerSensor{
+		Named:   conf.ResourceName().AsNamed(),
+		logger:  logger,
+		voltage: newConf.Voltage,
+		current: newConf.Current,
+		power:   newConf.Power,
+	}
+	ps.unitID, err = unitIDOrDefault(newConf.UnitID)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := GlobalClientRegistry.Get(newConf.ModbusClient)
+	if err != nil {
+		return nil, err
+	}
+	ps.mc = client
+	return ps, nil
+}
+
+func (ps *powerSensor) read(name string, q *powerQuantity) (float64, error) {
+	if q == nil {
+		return 0, fmt.Errorf("%s is not configured", name)
+	}
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	return q.point().read(ps.mc, ps.unitID)
+}
+
+// Returns the voltage in volts and whether it is AC
+func (ps *powerSensor) Voltage(ctx context.Context, extra map[string]interface{}) (float64, bool, error) {
+	v, err := ps.read("voltage", ps.voltage)
+	if err != nil {
+		return 0, false, err
+	}
+	return v, ps.voltage.AC, nil
+}
+
+// Returns the current in amperes and whether it is AC
+func (ps *powerSensor) Current(ctx context.Context, extra map[string]interface{}) (float64, bool, error) {
+	v, err := ps.read("current", ps.current)
+	if err != nil {
+		return 0, false, err
+	}
+	return v, ps.current.AC, nil
+}
+
+// Returns the power in watts
+func (ps *powerSensor) Power(ctx context.Context, extra map[string]interface{}) (float64, error) {
+	return ps.read("power", ps.power)
+}
+
+// Returns all configured quantities
+func (ps *powerSensor) Readings(ctx context.Context, extra map[string]interface{}) (map[string]interface{}, error) {
+	readings := map[string]interface{}{}
+	if ps.voltage != nil {
+		volts, isAC, err := ps.Voltage(ctx, extra)
+		if err != nil {
+			return nil, err
+		}
+		readings["volts"] = volts
+		readings["is_ac"] = isAC
+	}
+	if ps.current != nil {
+		amps, isAC, err := ps.Current(ctx, extra)
+		if err != nil {
+			return nil, err
+		}
+		readings["amps"] = amps
+		readings["is_ac"] = isAC
+	}
+	if ps.power != nil {
+		watts, err := ps.Power(ctx, extra)
+		if err != nil {
+			return nil, err
+		}
+		readings["watts"] = watts
+	}
+	return readings, nil
+}
+
+func (ps *powerSensor) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	return nil, fmt.Errorf("DoCommand not implemented")
+}
+
+func (ps *powerSensor) Close(ctx context.Context) error {
+	return nil
+}
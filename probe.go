@@ -0,0 +1,43 @@
+package viammodbus
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/simonvetter/modbus"
+	"go.viam.com/rdk/logging"
+)
+
+// Timeout of a probe unless configured, short as most probes get no answer
+const defaultProbeTimeout = 200 * time.Millisecond
+
+// Opens a short-lived transport with the settings and reads one holding register of the unit.
+// Returns nil if a device answered, with data or with an exception.
+func probeDevice(cfg transportConfig, unitID uint8, addr uint16, logger logging.Logger) error {
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultProbeTimeout
+	}
+	t, err := newDeviceTransport(cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer t.Close()
+
+	req := []byte{0x03, 0, 0, 0, 1}
+	binary.BigEndian.PutUint16(req[1:], addr)
+	res, err := t.exchange(unitID, req)
+	if err != nil {
+		return err
+	}
+	switch {
+	case len(res) == 2 && res[0] == 0x83:
+		// Gateways answer for devices that are not there
+		if code := res[1]; code == exceptionCodes[modbus.ErrGWPathUnavailable] || code == exceptionCodes[modbus.ErrGWTargetFailedToRespond] {
+			return modbus.ErrGWTargetFailedToRespond
+		}
+		return nil
+	case len(res) == 4 && res[0] == 0x03 && res[1] == 2:
+		return nil
+	}
+	return modbus.ErrProtocolError
+}
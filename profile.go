@@ -0,0 +1,117 @@
+package viammodbus
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Device profiles shipped with the module
+//
+//go:embed profiles/*.json
+var builtinProfiles embed.FS
+
+// ModbusProfile is a reusable register map for a device model
+type ModbusProfile struct {
+	Description string         `json:"description"`
+	Addressing  string         `json:"addressing"`
+	Blocks      []ModbusBlocks `json:"blocks"`
+}
+
+// Loads a profile by built-in name or from a file path
+func LoadProfile(name string) (*ModbusProfile, error) {
+	var data []byte
+	var err error
+	if isProfilePath(name) {
+		data, err = os.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read profile %q: %w", name, err)
+		}
+	} else {
+		data, err = builtinProfiles.ReadFile(path.Join("profiles", name+".json"))
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("unknown profile %q, available profiles: %s", name, strings.Join(BuiltinProfileNames(), ", "))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	profile := &ModbusProfile{}
+	if err := json.Unmarshal(data, profile); err != nil {
+		return nil, fmt.Errorf("failed to parse profile %q: %w", name, err)
+	}
+	if err := validateAddressing(profile.Addressing); err != nil {
+		return nil, fmt.Errorf("profile %q: %w", name, err)
+	}
+	if len(profile.Blocks) == 0 {
+		return nil, fmt.Errorf("profile %q does not define any blocks", name)
+	}
+	return profile, nil
+}
+
+// Returns the names of all profiles shipped with the module
+func BuiltinProfileNames() []string {
+	entries, err := builtinProfiles.ReadDir("profiles")
+	if err != nil {
+		return nil
+	}
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, strings.TrimSuffix(entry.Name(), ".json"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+func isProfilePath(name string) bool {
+	return strings.ContainsRune(name, os.PathSeparator) || strings.ContainsRune(name, '/') || strings.HasSuffix(name, ".json")
+}
+
+// Merges the instance blocks into the profile blocks. A block with the same name as a
+// profile block overrides the fields it sets, any other block is appended.
+func mergeBlocks(base []ModbusBlocks, overrides []ModbusBlockConfig) []ModbusBlocks {
+	merged := make([]ModbusBlocks, len(base))
+	copy(merged, base)
+
+	index := make(map[string]int, len(merged))
+	for i, block := range merged {
+		index[block.Name] = i
+	}
+
+	for _, override := range overrides {
+		i, got := index[override.Name]
+		if !got {
+			index[override.Name] = len(merged)
+			merged = append(merged, override.block())
+			continue
+		}
+		block := merged[i]
+		if override.Offset != nil {
+			block.Offset = *override.Offset
+		}
+		if override.Length != nil {
+			block.Length = *override.Length
+		}
+		if override.Type != "" {
+			block.Type = override.Type
+		}
+		if override.Register != "" {
+			block.Register = override.Register
+		}
+		if override.Scale != nil {
+			block.Scale = *override.Scale
+		}
+		if override.Enum != nil {
+			block.Enum = override.Enum
+		}
+		merged[i] = block
+	}
+	return merged
+}
@@ -0,0 +1,92 @@
+package viammodbus
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.viam.com/test"
+)
+
+func TestLoadProfile(t *testing.T) {
+	profile, err := LoadProfile("schneider_pm5000")
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, profile.Addressing, test.ShouldEqual, AddressingOneBased)
+	test.That(t, profile.Blocks[0], test.ShouldResemble, ModbusBlocks{Name: "current_a", Offset: 3000, Type: "float32"})
+	test.That(t, BuiltinProfileNames(), test.ShouldContain, "schneider_pm5000")
+
+	_, err = LoadProfile("unknown_meter")
+	test.That(t, err, test.ShouldNotBeNil)
+	test.That(t, err.Error(), test.ShouldContainSubstring, "schneider_pm5000")
+
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		test.That(t, os.WriteFile(path, []byte(content), 0o600), test.ShouldBeNil)
+		return path
+	}
+	profile, err = LoadProfile(write("meter.json", `{"blocks": [{"name": "power", "offset": 0, "type": "int16", "scale": 0.1}]}`))
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, profile.Blocks, test.ShouldResemble, []ModbusBlocks{{Name: "power", Offset: 0, Type: "int16", Scale: 0.1}})
+
+	_, err = LoadProfile(write("empty.json", `{"blocks": []}`))
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = LoadProfile(write("addressing.json", `{"addressing": "two_based", "blocks": [{"name": "power", "type": "int16"}]}`))
+	test.That(t, err, test.ShouldNotBeNil)
+	_, err = LoadProfile(filepath.Join(dir, "missing.json"))
+	test.That(t, err, test.ShouldNotBeNil)
+}
+
+func TestMergeBlocks(t *testing.T) {
+	zero, one := 0, 1
+	zeroScale := 0.0
+	base := []ModbusBlocks{
+		{Name: "voltage", Offset: 10, Type: "uint16", Scale: 0.1},
+		{Name: "energy", Offset: 20, Type: "float32"},
+		{Name: "state", Offset: 30, Type: "uint16", Enum: map[string]string{"0": "off"}},
+	}
+	merged := mergeBlocks(base, []ModbusBlockConfig{
+		// Zero values that are set replace the profile values
+		{Name: "voltage", Offset: &zero, Scale: &zeroScale},
+		// Unset fields are inherited
+		{Name: "energy", Type: "uint32"},
+		{Name: "serial", Offset: &one, Type: "bytes", Length: &one},
+	})
+	test.That(t, merged, test.ShouldResemble, []ModbusBlocks{
+		{Name: "voltage", Offset: 0, Type: "uint16", Scale: 0},
+		{Name: "energy", Offset: 20, Type: "uint32"},
+		{Name: "state", Offset: 30, Type: "uint16", Enum: map[string]string{"0": "off"}},
+		{Name: "serial", Offset: 1, Type: "bytes", Length: 1},
+	})
+	// The profile blocks are not modified
+	test.That(t, base[0].Offset, test.ShouldEqual, 10)
+}
+
+func TestResolveProfileBlocks(t *testing.T) {
+	offset := 1
+	scale := 0.0
+	cfg := &ModbusSensorConfig{
+		Profile:    "schneider_pm5000",
+		Addressing: AddressingOneBased,
+		Blocks: []ModbusBlockConfig{
+			{Name: "current_a", Offset: &offset},
+			{Name: "current_b", Scale: &scale},
+		},
+	}
+	blocks, err := cfg.resolveBlocks()
+	test.That(t, err, test.ShouldBeNil)
+	byName := map[string]ModbusBlocks{}
+	for _, block := range blocks {
+		byName[block.Name] = block
+	}
+	// The override offset is converted with the addressing of the sensor, inherited offsets
+	// with the addressing of the profile
+	test.That(t, byName["current_a"].Offset, test.ShouldEqual, 0)
+	test.That(t, byName["current_b"].Offset, test.ShouldEqual, 3001)
+	test.That(t, byName["current_b"].Type, test.ShouldEqual, "float32")
+
+	// A new block without an offset is at address 0, which one_based addressing rejects
+	cfg.Blocks = []ModbusBlockConfig{{Name: "extra", Type: "uint16"}}
+	_, err = cfg.resolveBlocks()
+	test.That(t, err, test.ShouldNotBeNil)
+}
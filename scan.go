@@ -0,0 +1,368 @@
+package viammodbus
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/simonvetter/modbus"
+)
+
+// Defaults of the scan DoCommand
+const (
+	defaultScanEnd         = 999
+	defaultScanDelayMs     = 50
+	defaultScanMaxRequests = 5000
+)
+
+// Tables a scan searches, named like the sensor block types, with the most addresses a
+// single request reads
+var scanTables = []struct {
+	name    string
+	maxRead int
+}{
+	{"coils", 2000},
+	{"discrete_inputs", 2000},
+	{"holding_registers", 125},
+	{"input_registers", 125},
+}
+
+type scanConfig struct {
+	unitIDs     []int
+	tables      map[string]bool
+	start       int
+	end         int
+	step        int
+	delay       time.Duration
+	maxRequests int
+}
+
+func parseScanConfig(args interface{}) (*scanConfig, error) {
+	cfg := &scanConfig{
+		tables:      map[string]bool{},
+		end:         defaultScanEnd,
+		step:        1,
+		delay:       defaultScanDelayMs * time.Millisecond,
+		maxRequests: defaultScanMaxRequests,
+	}
+	m, ok := args.(map[string]interface{})
+	if !ok && args != nil && args != true {
+		return nil, fmt.Errorf("scan must be an object or true")
+	}
+
+	for key, value := range m {
+		switch key {
+		case "unit_ids":
+			ids, ok := value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("scan unit_ids must be a list of unit ids")
+			}
+			for _, v := range ids {
+				id, err := readingToFloat(v)
+				if err != nil || id < 1 || id > 247 || id != float64(int(id)) {
+					return nil, fmt.Errorf("scan unit_ids must be between 1 and 247, got %v", v)
+				}
+				cfg.unitIDs = append(cfg.unitIDs, int(id))
+			}
+		case "tables":
+			tables, ok := value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("scan tables must be a list of table names")
+			}
+			for _, v := range tables {
+				name, _ := v.(string)
+				if !isScanTable(name) {
+					return nil, fmt.Errorf("scan tables must be coils, discrete_inputs, holding_registers or input_registers, got %v", v)
+				}
+				cfg.tables[name] = true
+			}
+		case "start", "end", "step", "delay_ms", "max_requests":
+			f, err := readingToFloat(value)
+			if err != nil || f < 0 || f != float64(int(f)) {
+				return nil, fmt.Errorf("scan %s must be a non-negative integer, got %v", key, value)
+			}
+			switch key {
+			case "start":
+				cfg.start = int(f)
+			case "end":
+				cfg.end = int(f)
+			case "step":
+				cfg.step = int(f)
+			case "delay_ms":
+				cfg.delay = time.Duration(f) * time.Millisecond
+			case "max_requests":
+				cfg.maxRequests = int(f)
+			}
+		default:
+			return nil, fmt.Errorf("unknown scan option %q", key)
+		}
+	}
+
+	if cfg.end > 65535 || cfg.start > cfg.end {
+		return nil, fmt.Errorf("scan range must satisfy start <= end <= 65535, got %d-%d", cfg.start, cfg.end)
+	}
+	if cfg.step < 1 {
+		return nil, fmt.Errorf("scan step must be at least 1, got %d", cfg.step)
+	}
+	if len(cfg.unitIDs) == 0 {
+		for id := 1; id <= 247; id++ {
+			cfg.unitIDs = append(cfg.unitIDs, id)
+		}
+	}
+	if len(cfg.tables) == 0 {
+		for _, t := range scanTables {
+			cfg.tables[t.name] = true
+		}
+	}
+	return cfg, nil
+}
+
+func isScanTable(name string) bool {
+	for _, t := range scanTables {
+		if t.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Probes the unit ids of the bus, then searches the readable address ranges of every unit
+// that answered. A unit answers with data or an exception, a timeout means nobody is there.
+type scanner struct {
+	ctx      context.Context
+	mc       *modbusClient
+	cfg      *scanConfig
+	requests int
+	last     time.Time
+}
+
+var errScanBudget = fmt.Errorf("scan max_requests reached")
+
+func (mc *modbusClient) scan(ctx context.Context, args interface{}) (map[string]interface{}, error) {
+	cfg, err := parseScanConfig(args)
+	if err != nil {
+		return nil, err
+	}
+	s := &scanner{ctx: ctx, mc: mc, cfg: cfg}
+	mc.logger.Infof("Scanning %d unit ids, addresses %d-%d", len(cfg.unitIDs), cfg.start, cfg.end)
+
+	units := []interface{}{}
+	silent := []interface{}{}
+	truncated := false
+	for _, id := range cfg.unitIDs {
+		unitID := uint8(id)
+		// Reading a single holding register is the cheapest request every device understands
+		err := s.read(unitID, "holding_registers", cfg.start, 1)
+		if err == errScanBudget {
+			truncated = true
+			break
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		if err != nil && (!isModbusException(err) || isScanNoResponse(err)) {
+			if err != modbus.ErrRequestTimedOut {
+				mc.logger.Debugf("Unit id %d did not respond: %v", id, err)
+			}
+			silent = append(silent, id)
+			continue
+		}
+
+		unit, err := s.scanUnit(unitID)
+		if err == errScanBudget {
+			truncated = true
+		} else if err != nil {
+			return nil, err
+		}
+		units = append(units, unit)
+		if truncated {
+			break
+		}
+	}
+	if truncated {
+		mc.logger.Warnf("Scan stopped after %d requests", s.requests)
+	}
+	return map[string]interface{}{
+		"units":       units,
+		"no_response": silent,
+		"requests":    s.requests,
+		"truncated":   truncated,
+	}, nil
+}
+
+// Returns true if the error means the device did not answer rather than answered with an
+// exception, including the exceptions of gateways in front of the device
+func isScanNoResponse(err error) bool {
+	return err == modbus.ErrRequestTimedOut || err == modbus.ErrGWTargetFailedToRespond || err == modbus.ErrGWPathUnavailable
+}
+
+// Result of searching one table of a unit
+type tableScan struct {
+	ranges     [][2]int
+	exceptions map[string]interface{}
+	timeouts   int
+	supported  bool
+}
+
+// Searches the readable ranges of every table of a unit and turns them into sensor blocks
+func (s *scanner) scanUnit(unitID uint8) (map[string]interface{}, error) {
+	tables := map[string]interface{}{}
+	blocks := []interface{}{}
+	var err error
+	for _, t := range scanTables {
+		if !s.cfg.tables[t.name] {
+			continue
+		}
+		result := &tableScan{exceptions: map[string]interface{}{}, supported: true}
+		err = s.searchTable(unitID, t.name, t.maxRead, result)
+		if err == modbus.ErrIllegalFunction {
+			err = nil
+		}
+		ranges := []interface{}{}
+		for _, r := range result.ranges {
+			ranges = append(ranges, map[string]interface{}{"start": r[0], "end": r[1]})
+			for offset := r[0]; offset <= r[1]; offset += t.maxRead {
+				blocks = append(blocks, map[string]interface{}{
+					"name":   fmt.Sprintf("%s_%d", t.name, offset),
+					"type":   t.name,
+					"offset": offset,
+					"length": min(t.maxRead, r[1]-offset+1),
+				})
+			}
+		}
+		tables[t.name] = map[string]interface{}{
+			"supported":  result.supported,
+			"ranges":     ranges,
+			"exceptions": result.exceptions,
+			"timeouts":   result.timeouts,
+		}
+		if err != nil {
+			break
+		}
+	}
+	return map[string]interface{}{
+		"unit_id": int(unitID),
+		"tables":  tables,
+		"blocks":  blocks,
+	}, err
+}
+
+// Walks the addresses of the table. From a readable address the end of its range is binary
+// searched, assuming a device that reads a range also reads any shorter range from the same
+// start. Unreadable addresses are skipped step addresses at a time.
+func (s *scanner) searchTable(unitID uint8, table string, maxRead int, result *tableScan) error {
+	for pos := s.cfg.start; pos <= s.cfg.end; {
+		ok, err := s.readable(unitID, table, pos, 1, result)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			pos += s.cfg.step
+			continue
+		}
+
+		n := min(maxRead, s.cfg.end-pos+1)
+		if n > 1 {
+			if ok, err = s.readable(unitID, table, pos, n, result); err != nil {
+				return err
+			}
+			if !ok {
+				// lo addresses are readable, hi are not
+				lo, hi := 1, n
+				for hi-lo > 1 {
+					mid := lo + (hi-lo)/2
+					ok, err := s.readable(unitID, table, pos, mid, result)
+					if err != nil {
+						return err
+					}
+					if ok {
+						lo = mid
+					} else {
+						hi = mid
+					}
+				}
+				n = lo
+			}
+		}
+
+		// Merge with the previous range if they touch
+		if last := len(result.ranges) - 1; last >= 0 && result.ranges[last][1] == pos-1 {
+			result.ranges[last][1] = pos + n - 1
+		} else {
+			result.ranges = append(result.ranges, [2]int{pos, pos + n - 1})
+		}
+		pos += n
+	}
+	return nil
+}
+
+// Returns true if the range can be read. Exceptions and timeouts are counted, an error is
+// only returned if the scan of the table has to stop.
+func (s *scanner) readable(unitID uint8, table string, addr, quantity int, result *tableScan) (bool, error) {
+	err := s.read(unitID, table, addr, quantity)
+	switch {
+	case err == nil:
+		return true, nil
+	case err == errScanBudget:
+		return false, err
+	case s.ctx.Err() != nil:
+		return false, s.ctx.Err()
+	case err == modbus.ErrIllegalFunction:
+		// The device does not implement the table at all
+		result.supported = false
+		return false, err
+	case isModbusException(err) && !isScanNoResponse(err):
+		count, _ := result.exceptions[err.Error()].(int)
+		result.exceptions[err.Error()] = count + 1
+	default:
+		result.timeouts++
+	}
+	return false, nil
+}
+
+// Sends one read, waiting delay_ms since the previous request. Timeouts are expected while
+// scanning and do not re-open the connection.
+func (s *scanner) read(unitID uint8, table string, addr, quantity int) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+	if s.requests >= s.cfg.maxRequests {
+		return errScanBudget
+	}
+	if wait := s.cfg.delay - time.Since(s.last); wait > 0 {
+		select {
+		case <-s.ctx.Done():
+			return s.ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+	s.requests++
+	defer func() { s.last = time.Now() }()
+
+	c := s.mc.client
+	read := func() (err error) {
+		switch table {
+		case "coils":
+			_, err = c.ReadCoils(uint16(addr), uint16(quantity))
+		case "discrete_inputs":
+			_, err = c.ReadDiscreteInputs(uint16(addr), uint16(quantity))
+		case "holding_registers":
+			_, err = c.ReadRawBytes(uint16(addr), uint16(2*quantity), modbus.HOLDING_REGISTER)
+		case "input_registers":
+			_, err = c.ReadRawBytes(uint16(addr), uint16(2*quantity), modbus.INPUT_REGISTER)
+		}
+		return err
+	}
+
+	s.mc.mu.Lock()
+	s.mc.client.SetUnitId(unitID)
+	err := read()
+	s.mc.mu.Unlock()
+	if err != nil && err != modbus.ErrRequestTimedOut && !isModbusException(err) {
+		s.mc.logger.Debugf("Scan request failed: %v", err)
+		if err := s.mc.reConnect(err); err != nil {
+			s.mc.logger.Debugf("Failed to reconnect: %v", err)
+		}
+	}
+	return err
+}
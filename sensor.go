@@ -5,6 +5,8 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/simonvetter/modbus"
@@ -27,18 +29,136 @@ func init() {
 }
 
 type ModbusSensorConfig struct {
-	ModbusClient  string         `json:"modbus_connection_name"`
-	Blocks        []ModbusBlocks `json:"blocks"`
-	UnitID        int            `json:"unit_id"`
-	ComponentType string         `json:"component_type"`
-	ComponentDesc string         `json:"component_description"`
+	ModbusClient  string              `json:"modbus_connection_name"`
+	Profile       string              `json:"profile"`
+	RegisterMap   string              `json:"register_map_csv"`
+	CSVColumns    *CSVColumns         `json:"csv_columns"`
+	CSVDelimiter  string              `json:"csv_delimiter"`
+	Blocks        []ModbusBlockConfig `json:"blocks"`
+	Addressing    string              `json:"addressing"`
+	UnitID        int                 `json:"unit_id"`
+	ComponentType string              `json:"component_type"`
+	ComponentDesc string              `json:"component_description"`
+}
+
+// Block of the sensor configuration. Offset, length and scale are pointers so a block
+// overriding a profile block can set them to zero.
+type ModbusBlockConfig struct {
+	Offset   *int              `json:"offset"`
+	Length   *int              `json:"length"`
+	Type     string            `json:"type"`
+	Name     string            `json:"name"`
+	Register string            `json:"register,omitempty"`
+	Scale    *float64          `json:"scale,omitempty"`
+	Enum     map[string]string `json:"enum,omitempty"`
+}
+
+// Returns the block, unset fields are zero
+func (cfg ModbusBlockConfig) block() ModbusBlocks {
+	block := ModbusBlocks{Type: cfg.Type, Name: cfg.Name, Register: cfg.Register, Enum: cfg.Enum}
+	if cfg.Offset != nil {
+		block.Offset = *cfg.Offset
+	}
+	if cfg.Length != nil {
+		block.Length = *cfg.Length
+	}
+	if cfg.Scale != nil {
+		block.Scale = *cfg.Scale
+	}
+	return block
+}
+
+// Returns the configuration of a register map block. Its offset is always set, a zero length
+// or scale means the column was empty.
+func blockConfigOf(block ModbusBlocks) ModbusBlockConfig {
+	cfg := ModbusBlockConfig{Offset: &block.Offset, Type: block.Type, Name: block.Name, Register: block.Register, Enum: block.Enum}
+	if block.Length != 0 {
+		cfg.Length = &block.Length
+	}
+	if block.Scale != 0 {
+		cfg.Scale = &block.Scale
+	}
+	return cfg
 }
 
 type ModbusBlocks struct {
-	Offset int    `json:"offset"`
-	Length int    `json:"length"`
-	Type   string `json:"type"`
-	Name   string `json:"name"`
+	Offset   int               `json:"offset"`
+	Length   int               `json:"length"`
+	Type     string            `json:"type"`
+	Name     string            `json:"name"`
+	Register string            `json:"register,omitempty"`
+	Scale    float64           `json:"scale,omitempty"`
+	Enum     map[string]string `json:"enum,omitempty"`
+}
+
+// Returns the blocks of the configured profile and register map merged with the instance blocks,
+// all offsets converted to zero-based addresses
+func (cfg *ModbusSensorConfig) resolveBlocks() ([]ModbusBlocks, error) {
+	blocks := []ModbusBlocks{}
+	if cfg.Profile != "" {
+		profile, err := LoadProfile(cfg.Profile)
+		if err != nil {
+			return nil, err
+		}
+		blocks, err = convertAddresses(profile.Addressing, profile.Blocks)
+		if err != nil {
+			return nil, fmt.Errorf("profile %q: %w", cfg.Profile, err)
+		}
+	}
+	if cfg.RegisterMap != "" {
+		csvBlocks, err := LoadRegisterMapCSV(cfg.RegisterMap, cfg.CSVColumns, cfg.CSVDelimiter)
+		if err != nil {
+			return nil, err
+		}
+		csvBlocks, err = convertAddresses(cfg.Addressing, csvBlocks)
+		if err != nil {
+			return nil, fmt.Errorf("register_map_csv: %w", err)
+		}
+		overrides := make([]ModbusBlockConfig, 0, len(csvBlocks))
+		for _, block := range csvBlocks {
+			overrides = append(overrides, blockConfigOf(block))
+		}
+		blocks = mergeBlocks(blocks, overrides)
+	}
+	instanceBlocks, err := convertOverrides(cfg.Addressing, cfg.Blocks, blocks)
+	if err != nil {
+		return nil, err
+	}
+	return mergeBlocks(blocks, instanceBlocks), nil
+}
+
+// Converts the block offsets to zero-based addresses
+func convertAddresses(addressing string, blocks []ModbusBlocks) ([]ModbusBlocks, error) {
+	converted := make([]ModbusBlocks, 0, len(blocks))
+	for _, block := range blocks {
+		block, err := toZeroBased(addressing, block)
+		if err != nil {
+			return nil, err
+		}
+		converted = append(converted, block)
+	}
+	return converted, nil
+}
+
+// Converts the offsets of the instance blocks to zero-based addresses. Blocks overriding a
+// base block without an offset are left untouched.
+func convertOverrides(addressing string, blocks []ModbusBlockConfig, base []ModbusBlocks) ([]ModbusBlockConfig, error) {
+	inherited := map[string]bool{}
+	for _, block := range base {
+		inherited[block.Name] = true
+	}
+	converted := make([]ModbusBlockConfig, 0, len(blocks))
+	for _, cfg := range blocks {
+		if cfg.Offset != nil || !inherited[cfg.Name] {
+			block, err := toZeroBased(addressing, cfg.block())
+			if err != nil {
+				return nil, err
+			}
+			cfg.Offset, cfg.Register = &block.Offset, block.Register
+		}
+		converted = append(converted, cfg)
+	}
+	return converted, nil
 }
 
 func (cfg *ModbusSensorConfig) Validate(path string) ([]string, []string, error) {
@@ -46,23 +166,47 @@ func (cfg *ModbusSensorConfig) Validate(path string) ([]string, []string, error)
 		return nil, nil, resource.NewConfigValidationFieldRequiredError(path, "modbus_connection_name")
 	}
 
-	if cfg.Blocks == nil {
+	if cfg.Blocks == nil && cfg.Profile == "" && cfg.RegisterMap == "" {
 		return nil, nil, errors.New("blocks is required")
 	}
 
+	if err := validateAddressing(cfg.Addressing); err != nil {
+		return nil, nil, err
+	}
+
+	blocks, err := cfg.resolveBlocks()
+	if err != nil {
+		return nil, nil, err
+	}
+
 	nameCount := make(map[string]int)
-	for i, block := range cfg.Blocks {
+	for i, block := range blocks {
 		if block.Name == "" {
 			return nil, nil, fmt.Errorf("name is required in block %v", i)
 		}
 		if block.Type == "" {
-			return nil, nil, fmt.Errorf("type is required in block %v", i)
+			return nil, nil, fmt.Errorf("type is required in %s", blockRef(i, block))
+		}
+		if _, got := blockTypeWidths[block.Type]; !got {
+			return nil, nil, fmt.Errorf("unknown type %q in %s, must be one of %s", block.Type, blockRef(i, block), strings.Join(blockTypeNames(), ", "))
 		}
 		if block.Offset < 0 {
-			return nil, nil, fmt.Errorf("offset must be non-negative in block %v", i)
+			return nil, nil, fmt.Errorf("offset must be non-negative in %s", blockRef(i, block))
+		}
+		if block.Register != "" && block.Register != "holding" && block.Register != "input" {
+			return nil, nil, fmt.Errorf("register must be holding or input in %s, got %q", blockRef(i, block), block.Register)
+		}
+		if block.Register == "input" && blockTable(block) != "input_registers" {
+			return nil, nil, fmt.Errorf("register input is not supported for %s in %s", block.Type, blockRef(i, block))
 		}
 		if shouldCheckLength(block.Type) && block.Length <= 0 {
-			return nil, nil, fmt.Errorf("length must be non-zero and non-negative in block %v", i)
+			return nil, nil, fmt.Errorf("length must be non-zero and non-negative in %s", blockRef(i, block))
+		}
+		if limit := maxBlockLength(block.Type); shouldCheckLength(block.Type) && block.Length > limit {
+			return nil, nil, fmt.Errorf("length must not exceed the protocol maximum of %d for %s in %s, got %d", limit, block.Type, blockRef(i, block), block.Length)
+		}
+		if end := block.Offset + blockWidth(block); end > 65536 {
+			return nil, nil, fmt.Errorf("%s ends at address %d, beyond the last address 65535", blockRef(i, block), end-1)
 		}
 		nameCount[block.Name]++
 	}
@@ -77,9 +221,106 @@ func (cfg *ModbusSensorConfig) Validate(path string) ([]string, []string, error)
 		}
 	}
 
+	for _, warning := range blockOverlapWarnings(blocks) {
+		fmt.Println("Warning: " + warning)
+	}
+
 	return []string{string(cfg.ModbusClient)}, nil, nil
 }
 
+// Number of addresses read by each block type, 0 if the block length is used
+var blockTypeWidths = map[string]int{
+	"coils":             0,
+	"discrete_inputs":   0,
+	"holding_registers": 0,
+	"input_registers":   0,
+	"bytes":             0,
+	"rawBytes":          0,
+	"uint8":             1,
+	"int16":             1,
+	"uint16":            1,
+	"int32":             2,
+	"uint32":            2,
+	"float32":           2,
+	"float64":           4,
+}
+
+func blockTypeNames() []string {
+	names := make([]string, 0, len(blockTypeWidths))
+	for name := range blockTypeWidths {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Returns the number of coils or registers a block reads
+func blockWidth(block ModbusBlocks) int {
+	switch block.Type {
+	case "bytes", "rawBytes":
+		// length is in bytes, two per register
+		return (block.Length + 1) / 2
+	}
+	if width := blockTypeWidths[block.Type]; width > 0 {
+		return width
+	}
+	return block.Length
+}
+
+// Returns the maximum length of a single read request for the block type
+func maxBlockLength(t string) int {
+	switch t {
+	case "coils", "discrete_inputs":
+		return 2000
+	case "bytes", "rawBytes":
+		return 250
+	default:
+		return 125
+	}
+}
+
+// Returns the modbus table a block reads from
+func blockTable(block ModbusBlocks) string {
+	switch block.Type {
+	case "coils", "discrete_inputs", "input_registers", "holding_registers":
+		return block.Type
+	}
+	if blockRegType(block) == modbus.INPUT_REGISTER {
+		return "input_registers"
+	}
+	return "holding_registers"
+}
+
+func blockRef(i int, block ModbusBlocks) string {
+	return fmt.Sprintf("block %v (%s)", i, block.Name)
+}
+
+// Returns a warning for every pair of blocks reading overlapping addresses of the same table
+func blockOverlapWarnings(blocks []ModbusBlocks) []string {
+	type span struct {
+		start, end int
+		name       string
+	}
+	tables := map[string][]span{}
+	for _, block := range blocks {
+		t := blockTable(block)
+		tables[t] = append(tables[t], span{block.Offset, block.Offset + blockWidth(block), block.Name})
+	}
+
+	warnings := []string{}
+	for _, t := range []string{"coils", "discrete_inputs", "holding_registers", "input_registers"} {
+		spans := tables[t]
+		sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+		for i := range spans {
+			for j := i + 1; j < len(spans) && spans[j].start < spans[i].end; j++ {
+				warnings = append(warnings, fmt.Sprintf("blocks %q and %q overlap in %s at addresses %d-%d",
+					spans[i].name, spans[j].name, t, spans[j].start, min(spans[i].end, spans[j].end)-1))
+			}
+		}
+	}
+	return warnings
+}
+
 func shouldCheckLength(t string) bool {
 	switch t {
 	case "coils", "discrete_inputs", "holding_registers", "input_registers", "bytes", "rawBytes":
@@ -96,13 +337,18 @@ func NewModbusSensor(ctx context.Context, deps resource.Dependencies, conf resou
 		return nil, err
 	}
 
+	blocks, err := newConf.resolveBlocks()
+	if err != nil {
+		return nil, err
+	}
+
 	c, cancelFunc := context.WithCancel(context.Background())
 	s := ModbusSensor{
 		Named:          conf.ResourceName().AsNamed(),
 		logger:         logger,
 		cancelFunc:     cancelFunc,
 		ctx:            c,
-		blocks:         newConf.Blocks,
+		blocks:         blocks,
 		component_type: newConf.ComponentType,
 		component_desc: newConf.ComponentDesc,
 	}
@@ -171,61 +417,59 @@ func (s *ModbusSensor) Readings(ctx context.Context, extra map[string]interface{
 			}
 			writeUInt16ArrayToOutput(b, block, results)
 		case "bytes":
-			b, e := s.mc.ReadBytes(uint16(block.Offset), uint16(block.Length), modbus.HOLDING_REGISTER, s.unitID)
+			b, e := s.mc.ReadBytes(uint16(block.Offset), uint16(block.Length), blockRegType(block), s.unitID)
 			if e != nil {
 				return nil, e
 			}
 			writeByteArrayToOutput(b, block, results)
 		case "rawBytes":
-			b, e := s.mc.ReadRawBytes(uint16(block.Offset), uint16(block.Length), modbus.HOLDING_REGISTER, s.unitID)
+			b, e := s.mc.ReadRawBytes(uint16(block.Offset), uint16(block.Length), blockRegType(block), s.unitID)
 			if e != nil {
 				return nil, e
 			}
 			writeByteArrayToOutput(b, block, results)
 		case "uint8":
-			b, e := s.mc.ReadUInt8(uint16(block.Offset), modbus.HOLDING_REGISTER, s.unitID)
+			b, e := s.mc.ReadUInt8(uint16(block.Offset), blockRegType(block), s.unitID)
 			if e != nil {
 				return nil, e
 			}
-			results[block.Name] = int32(b)
+			results[block.Name] = scaleValue(block, float64(b), int32(b))
 		case "int16":
-			b, e := s.mc.ReadInt16(uint16(block.Offset), modbus.HOLDING_REGISTER, s.unitID)
+			b, e := s.mc.ReadInt16(uint16(block.Offset), blockRegType(block), s.unitID)
 			if e != nil {
 				return nil, e
 			}
-			results[block.Name] = int32(b)
+			results[block.Name] = scaleValue(block, float64(b), int32(b))
 		case "uint16":
-			b, e := s.mc.ReadUInt16(uint16(block.Offset), modbus.HOLDING_REGISTER, s.unitID)
+			b, e := s.mc.ReadUInt16(uint16(block.Offset), blockRegType(block), s.unitID)
 			if e != nil {
 				return nil, e
 			}
-			results[block.Name] = int32(b)
+			results[block.Name] = scaleValue(block, float64(b), int32(b))
 		case "int32":
-			b, e := s.mc.ReadInt32(uint16(block.Offset), modbus.HOLDING_REGISTER, s.unitID)
+			b, e := s.mc.ReadInt32(uint16(block.Offset), blockRegType(block), s.unitID)
 			if e != nil {
 				return nil, e
 			}
-			results[block.Name] = b
+			results[block.Name] = scaleValue(block, float64(b), b)
 		case "uint32":
-			b, e := s.mc.ReadUInt32(uint16(block.Offset), modbus.HOLDING_REGISTER, s.unitID)
+			b, e := s.mc.ReadUInt32(uint16(block.Offset), blockRegType(block), s.unitID)
 			if e != nil {
 				return nil, e
 			}
-			results[block.Name] = b
+			results[block.Name] = scaleValue(block, float64(b), b)
 		case "float32":
-			b, e := s.mc.ReadFloat32(uint16(block.Offset), modbus.HOLDING_REGISTER, s.unitID)
+			b, e := s.mc.ReadFloat32(uint16(block.Offset), blockRegType(block), s.unitID)
 			if e != nil {
 				return nil, e
 			}
-			results[block.Name] = b
+			results[block.Name] = scaleValue(block, float64(b), b)
 		case "float64":
-			b, e := s.mc.ReadFloat64(uint16(block.Offset), modbus.HOLDING_REGISTER, s.unitID)
+			b, e := s.mc.ReadFloat64(uint16(block.Offset), blockRegType(block), s.unitID)
 			if e != nil {
 				return nil, e
 			}
-			results[block.Name] = b
-		default:
-			results[block.Name] = "unsupported type"
+			results[block.Name] = scaleValue(block, float64(b), b)
 		}
 	}
 
@@ -255,6 +499,17 @@ func (s *ModbusSensor) Close(ctx context.Context) error {
 	return nil
 }
 
+// Applies the block enum and scale to a numeric value, returns raw if neither is configured
+func scaleValue(block ModbusBlocks, value float64, raw interface{}) interface{} {
+	if label, got := block.Enum[fmt.Sprint(raw)]; got {
+		return label
+	}
+	if block.Scale != 0 {
+		return value * block.Scale
+	}
+	return raw
+}
+
 func writeBoolArrayToOutput(b []bool, block ModbusBlocks, results map[string]interface{}) {
 	// only rename block Name with "_0", "_1" if there are more than one in this array
 	if len(b) > 1 {
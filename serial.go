@@ -0,0 +1,155 @@
+package viammodbus
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/goburrow/serial"
+	"github.com/simonvetter/modbus"
+)
+
+// Baud rates serial devices commonly support
+var standardSpeeds = []uint{300, 600, 1200, 2400, 4800, 9600, 14400, 19200, 28800, 38400, 57600, 76800, 115200, 230400, 460800, 921600}
+
+// RS-485 driver settings of the serial port, applied by the Linux kernel driver
+type rs485Config struct {
+	Enabled           bool `json:"enabled"`
+	DelayBeforeSendMs int  `json:"delay_rts_before_send_ms"`
+	DelayAfterSendMs  int  `json:"delay_rts_after_send_ms"`
+	RTSHighDuringSend bool `json:"rts_high_during_send"`
+	RTSHighAfterSend  bool `json:"rts_high_after_send"`
+	RxDuringTx        bool `json:"rx_during_tx"`
+}
+
+func (cfg *rs485Config) validate() error {
+	if cfg.DelayBeforeSendMs < 0 || cfg.DelayAfterSendMs < 0 {
+		return fmt.Errorf("rs485 delay_rts_before_send_ms and delay_rts_after_send_ms must be non-negative")
+	}
+	if !cfg.Enabled {
+		if *cfg != (rs485Config{}) {
+			return fmt.Errorf("rs485 options require rs485 enabled")
+		}
+		return nil
+	}
+	if runtime.GOOS != "linux" {
+		return fmt.Errorf("rs485 options are only supported on linux, not %v", runtime.GOOS)
+	}
+	return nil
+}
+
+func (cfg *rs485Config) serialConfig() serial.RS485Config {
+	if cfg == nil {
+		return serial.RS485Config{}
+	}
+	return serial.RS485Config{
+		Enabled:            cfg.Enabled,
+		DelayRtsBeforeSend: time.Duration(cfg.DelayBeforeSendMs) * time.Millisecond,
+		DelayRtsAfterSend:  time.Duration(cfg.DelayAfterSendMs) * time.Millisecond,
+		RtsHighDuringSend:  cfg.RTSHighDuringSend,
+		RtsHighAfterSend:   cfg.RTSHighAfterSend,
+		RxDuringTx:         cfg.RxDuringTx,
+	}
+}
+
+// Returns the parity of the configuration, one of none, even or odd, or the number of the
+// Modbus library for older configurations
+func parseParity(value interface{}) (uint, error) {
+	switch v := value.(type) {
+	case nil:
+		return modbus.PARITY_NONE, nil
+	case string:
+		if v == "" {
+			return modbus.PARITY_NONE, nil
+		}
+		parity, err := GetParity(v)
+		if err != nil {
+			return 0, fmt.Errorf("parity must be %v, %v or %v, got %q", "none", "even", "odd", v)
+		}
+		return parity, nil
+	default:
+		n, err := readingToFloat(v)
+		parity := uint(n)
+		if err != nil || float64(parity) != n || (parity != modbus.PARITY_NONE && parity != modbus.PARITY_EVEN && parity != modbus.PARITY_ODD) {
+			return 0, fmt.Errorf("parity must be %v, %v or %v, got %v", "none", "even", "odd", v)
+		}
+		return parity, nil
+	}
+}
+
+func parityName(parity uint) string {
+	switch parity {
+	case modbus.PARITY_EVEN:
+		return "even"
+	case modbus.PARITY_ODD:
+		return "odd"
+	}
+	return "none"
+}
+
+// Checks the serial settings against the url scheme and each other
+func (cfg *modbusClientConfig) validateSerial(scheme string) error {
+	parity, err := parseParity(cfg.Parity)
+	if err != nil {
+		return err
+	}
+
+	switch scheme {
+	case "rtu", asciiScheme:
+	case "tcp", "tcp+tls", "udp", "rtuovertcp", "rtuoverudp":
+		// Over rtuovertcp and rtuoverudp the speed sets the delay between frames, the line
+		// itself is configured on the serial server
+		options := []string{}
+		if cfg.Speed != 0 && !strings.HasPrefix(scheme, "rtuover") {
+			options = append(options, "speed")
+		}
+		if cfg.DataBits != 0 {
+			options = append(options, "data_bits")
+		}
+		if cfg.Parity != nil {
+			options = append(options, "parity")
+		}
+		if cfg.StopBits != 0 {
+			options = append(options, "stop_bits")
+		}
+		if cfg.RS485 != nil {
+			options = append(options, "rs485")
+		}
+		if cfg.EchoSuppression {
+			options = append(options, "echo_suppression")
+		}
+		if len(options) > 0 {
+			return fmt.Errorf("%v only apply to serial urls, not %v://", strings.Join(options, ", "), scheme)
+		}
+		return nil
+	default:
+		return nil
+	}
+
+	if cfg.Speed != 0 && !isStandardSpeed(cfg.Speed) {
+		fmt.Printf("Warning: speed %d is not a standard baud rate\n", cfg.Speed)
+	}
+	if cfg.DataBits != 0 && cfg.DataBits != 7 && cfg.DataBits != 8 {
+		return fmt.Errorf("data_bits must be 7 or 8, got %d", cfg.DataBits)
+	}
+	if cfg.StopBits > 2 {
+		return fmt.Errorf("stop_bits must be 1 or 2, got %d", cfg.StopBits)
+	}
+	if parity != modbus.PARITY_NONE && cfg.StopBits == 2 {
+		fmt.Printf("Warning: parity %v with 2 stop bits, devices with parity usually expect 1 stop bit\n", parityName(parity))
+	}
+	if cfg.RS485 != nil {
+		return cfg.RS485.validate()
+	}
+	return nil
+}
+
+func isStandardSpeed(speed uint) bool {
+	for _, s := range standardSpeeds {
+		if s == speed {
+			return true
+		}
+	}
+	return false
+}
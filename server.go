@@ -0,0 +1,474 @@
+package viammodbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/simonvetter/modbus"
+	"go.viam.com/rdk/components/generic"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+)
+
+var ModbusServerModel = NamespaceFamily.WithModel("server")
+
+const (
+	defaultServerURL       = "tcp://0.0.0.0:502"
+	defaultServerRefreshMs = 1000
+)
+
+func init() {
+	resource.RegisterComponent(
+		generic.API,
+		ModbusServerModel,
+		resource.Registration[generic.Resource, *serverConfig]{
+			Constructor: newModbusServer,
+		})
+}
+
+type serverConfig struct {
+	URL        string              `json:"url"`
+	Timeout    int                 `json:"timeout_ms"`
+	MaxClients uint                `json:"max_clients"`
+	RefreshMs  int                 `json:"refresh_interval_ms"`
+	Endianness string              `json:"endianness"`
+	WordOrder  string              `json:"word_order"`
+	Points     []serverPointConfig `json:"points"`
+	Writes     []serverWriteConfig `json:"writes"`
+}
+
+// A coil, discrete input or register served from a readings key of a dependency
+type serverPointConfig struct {
+	Type     string  `json:"type"`
+	Offset   int     `json:"offset"`
+	DataType string  `json:"data_type"`
+	Scale    float64 `json:"scale"`
+	Source   string  `json:"source"`
+	Key      string  `json:"key"`
+}
+
+func (c *serverPointConfig) point() *pointConfig {
+	return &pointConfig{Type: c.Type, Offset: c.Offset, DataType: c.DataType, Scale: c.Scale}
+}
+
+func (cfg *serverConfig) Validate(path string) ([]string, []string, error) {
+	if cfg.URL != "" {
+		u, err := url.Parse(cfg.URL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid url %q: %w", cfg.URL, err)
+		}
+		if u.Scheme != "tcp" {
+			return nil, nil, fmt.Errorf("url scheme must be tcp, serving over rtu or other serial lines is not supported, got %q", u.Scheme)
+		}
+	}
+	if cfg.Timeout < 0 {
+		return nil, nil, fmt.Errorf("timeout_ms must be non-negative, got %d", cfg.Timeout)
+	}
+	if cfg.RefreshMs < 0 {
+		return nil, nil, fmt.Errorf("refresh_interval_ms must be non-negative, got %d", cfg.RefreshMs)
+	}
+	if cfg.Endianness != "" && cfg.Endianness != "big" && cfg.Endianness != "little" {
+		return nil, nil, fmt.Errorf("endianness must be %v or %v", "big", "little")
+	}
+	if cfg.WordOrder != "" && cfg.WordOrder != "high" && cfg.WordOrder != "low" {
+		return nil, nil, fmt.Errorf("word_order must be %v or %v", "high", "low")
+	}
+	if len(cfg.Points) == 0 && len(cfg.Writes) == 0 {
+		return nil, nil, errors.New("at least one of points or writes is required")
+	}
+
+	deps := []string{}
+	sources := map[string]bool{}
+	used := map[string]map[int]string{}
+	for i, p := range cfg.Points {
+		name := fmt.Sprintf("point %v", i)
+		if p.Source == "" {
+			return nil, nil, fmt.Errorf("source is required in %s", name)
+		}
+		if p.Key == "" {
+			return nil, nil, fmt.Errorf("key is required in %s", name)
+		}
+		if err := p.point().validate(name, false); err != nil {
+			return nil, nil, err
+		}
+		if !sources[p.Source] {
+			sources[p.Source] = true
+			deps = append(deps, p.Source)
+		}
+		if err := markServerAddresses(used, name, p.point()); err != nil {
+			return nil, nil, err
+		}
+	}
+	for i, w := range cfg.Writes {
+		name := fmt.Sprintf("write %v", i)
+		if err := w.validate(name); err != nil {
+			return nil, nil, err
+		}
+		if w.Target != "" && !sources[w.Target] {
+			sources[w.Target] = true
+			deps = append(deps, w.Target)
+		}
+		if err := markServerAddresses(used, name, w.point()); err != nil {
+			return nil, nil, err
+		}
+	}
+	return deps, nil, nil
+}
+
+// Records the addresses of the point and fails if another point already uses one of them
+func markServerAddresses(used map[string]map[int]string, name string, p *pointConfig) error {
+	if used[p.Type] == nil {
+		used[p.Type] = map[int]string{}
+	}
+	for addr := p.Offset; addr < p.Offset+p.width(); addr++ {
+		if other, got := used[p.Type][addr]; got {
+			return fmt.Errorf("%s overlaps %s at %v %d", name, other, p.Type, addr)
+		}
+		used[p.Type][addr] = name
+	}
+	return nil
+}
+
+type modbusServer struct {
+	resource.AlwaysRebuild
+	resource.Named
+	logger logging.Logger
+
+	server  *modbus.ModbusServer
+	points  []serverPointConfig
+	sources map[string]resource.Sensor
+	refresh time.Duration
+
+	// Writes by table and address, serialized by writeMu
+	writeMu sync.Mutex
+	writes  map[string]map[uint16]*serverWriteConfig
+	targets map[string]resource.Resource
+
+	endianness modbus.Endianness
+	wordOrder  modbus.WordOrder
+
+	mu             sync.RWMutex
+	coils          map[uint16]bool
+	discreteInputs map[uint16]bool
+	holding        map[uint16]uint16
+	input          map[uint16]uint16
+	setpoints      map[string]float64
+
+	cancel  context.CancelFunc
+	workers sync.WaitGroup
+}
+
+func newModbusServer(ctx context.Context, deps resource.Dependencies, conf resource.Config, logger logging.Logger) (generic.Resource, error) {
+	newConf, err := resource.NativeConfig[*serverConfig](conf)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &modbusServer{
+		Named:          conf.ResourceName().AsNamed(),
+		logger:         logger,
+		points:         newConf.Points,
+		sources:        map[string]resource.Sensor{},
+		refresh:        defaultServerRefreshMs * time.Millisecond,
+		endianness:     modbus.BIG_ENDIAN,
+		wordOrder:      modbus.HIGH_WORD_FIRST,
+		coils:          map[uint16]bool{},
+		discreteInputs: map[uint16]bool{},
+		holding:        map[uint16]uint16{},
+		input:          map[uint16]uint16{},
+		setpoints:      map[string]float64{},
+		writes:         map[string]map[uint16]*serverWriteConfig{},
+		targets:        map[string]resource.Resource{},
+	}
+	if newConf.RefreshMs > 0 {
+		s.refresh = time.Duration(newConf.RefreshMs) * time.Millisecond
+	}
+	if newConf.Endianness != "" {
+		if s.endianness, err = GetEndianness(newConf.Endianness); err != nil {
+			return nil, err
+		}
+	}
+	if newConf.WordOrder != "" {
+		if s.wordOrder, err = GetWordOrder(newConf.WordOrder); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, p := range s.points {
+		if _, got := s.sources[p.Source]; got {
+			continue
+		}
+		source, err := sensorFromDependencies(deps, p.Source)
+		if err != nil {
+			return nil, err
+		}
+		s.sources[p.Source] = source
+	}
+	if err := s.setupWrites(deps, newConf.Writes); err != nil {
+		return nil, err
+	}
+
+	// Serve the initial values from the first refresh
+	s.update(ctx)
+
+	serverURL := newConf.URL
+	if serverURL == "" {
+		serverURL = defaultServerURL
+	}
+	s.server, err = modbus.NewServer(&modbus.ServerConfiguration{
+		URL:        serverURL,
+		Timeout:    time.Duration(newConf.Timeout) * time.Millisecond,
+		MaxClients: newConf.MaxClients,
+	}, s)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.server.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start modbus server on %s: %w", serverURL, err)
+	}
+	logger.Infof("Modbus server listening on %s", serverURL)
+
+	refreshCtx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.workers.Add(1)
+	go s.refreshLoop(refreshCtx)
+	return s, nil
+}
+
+// Returns the dependency with the given name that provides readings
+func sensorFromDependencies(deps resource.Dependencies, name string) (resource.Sensor, error) {
+	dep, err := dependencyByName(deps, name)
+	if err != nil {
+		return nil, err
+	}
+	source, ok := dep.(resource.Sensor)
+	if !ok {
+		return nil, fmt.Errorf("dependency %q does not provide readings", name)
+	}
+	return source, nil
+}
+
+func dependencyByName(deps resource.Dependencies, name string) (resource.Resource, error) {
+	for depName, dep := range deps {
+		if depName.ShortName() == name || depName.Name == name {
+			return dep, nil
+		}
+	}
+	return nil, fmt.Errorf("dependency %q not found", name)
+}
+
+func (s *modbusServer) refreshLoop(ctx context.Context) {
+	defer s.workers.Done()
+	ticker := time.NewTicker(s.refresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.update(ctx)
+		}
+	}
+}
+
+// Reads every source once and encodes the readings into the register bank. Points whose
+// source or key is unavailable keep their previous value.
+func (s *modbusServer) update(ctx context.Context) {
+	readings := map[string]map[string]interface{}{}
+	for name, source := range s.sources {
+		r, err := source.Readings(ctx, nil)
+		if err != nil {
+			s.logger.Warnf("Failed to get readings from %s: %v", name, err)
+			continue
+		}
+		readings[name] = r
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range s.points {
+		r, got := readings[p.Source]
+		if !got {
+			continue
+		}
+		value, got := r[p.Key]
+		if !got {
+			s.logger.Debugf("Readings of %s have no key %q", p.Source, p.Key)
+			continue
+		}
+		if err := s.store(p, value); err != nil {
+			s.logger.Warnf("Failed to serve %s.%s at %v %d: %v", p.Source, p.Key, p.Type, p.Offset, err)
+		}
+	}
+}
+
+// Encodes the value into the bank, must be called with the lock held
+func (s *modbusServer) store(p serverPointConfig, value interface{}) error {
+	f, err := readingToFloat(value)
+	if err != nil {
+		return err
+	}
+	offset := uint16(p.Offset)
+	switch p.Type {
+	case pointCoil:
+		s.coils[offset] = f != 0
+		return nil
+	case pointDiscreteInput:
+		s.discreteInputs[offset] = f != 0
+		return nil
+	}
+
+	point := p.point()
+	regs, err := encodeRegisters(point.DataType, f/point.scale(), s.endianness, s.wordOrder)
+	if err != nil {
+		return err
+	}
+	bank := s.holding
+	if p.Type == pointInputRegister {
+		bank = s.input
+	}
+	for i, reg := range regs {
+		bank[offset+uint16(i)] = reg
+	}
+	return nil
+}
+
+// Converts a readings value to a number, booleans become 0 and 1
+func readingToFloat(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case int8:
+		return float64(v), nil
+	case int16:
+		return float64(v), nil
+	case int32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case uint:
+		return float64(v), nil
+	case uint8:
+		return float64(v), nil
+	case uint16:
+		return float64(v), nil
+	case uint32:
+		return float64(v), nil
+	case uint64:
+		return float64(v), nil
+	case bool:
+		return boolToFloat(v), nil
+	default:
+		return 0, fmt.Errorf("unsupported reading type %T", value)
+	}
+}
+
+// Encodes a raw value into the registers of the data type in the configured byte and word order
+func encodeRegisters(dataType string, value float64, endianness modbus.Endianness, wordOrder modbus.WordOrder) ([]uint16, error) {
+	bits, err := registerBits(dataType, value)
+	if err != nil {
+		return nil, err
+	}
+	regs := []uint16{uint16(bits)}
+	if (&pointConfig{DataType: dataType}).width() == 2 {
+		regs = []uint16{uint16(bits >> 16), uint16(bits)}
+		if wordOrder == modbus.LOW_WORD_FIRST {
+			regs[0], regs[1] = regs[1], regs[0]
+		}
+	}
+	if endianness == modbus.LITTLE_ENDIAN {
+		for i, reg := range regs {
+			regs[i] = reg<<8 | reg>>8
+		}
+	}
+	return regs, nil
+}
+
+func (s *modbusServer) HandleCoils(req *modbus.CoilsRequest) ([]bool, error) {
+	if req.IsWrite {
+		args := make([]uint16, len(req.Args))
+		for i, b := range req.Args {
+			args[i] = uint16(boolToFloat(b))
+		}
+		return nil, s.handleWrite(pointCoil, req.Addr, args)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return readBits(s.coils, req.Addr, req.Quantity)
+}
+
+func (s *modbusServer) HandleDiscreteInputs(req *modbus.DiscreteInputsRequest) ([]bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return readBits(s.discreteInputs, req.Addr, req.Quantity)
+}
+
+func (s *modbusServer) HandleHoldingRegisters(req *modbus.HoldingRegistersRequest) ([]uint16, error) {
+	if req.IsWrite {
+		return nil, s.handleWrite(pointHoldingRegister, req.Addr, req.Args)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return readRegisters(s.holding, req.Addr, req.Quantity)
+}
+
+func (s *modbusServer) HandleInputRegisters(req *modbus.InputRegistersRequest) ([]uint16, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return readRegisters(s.input, req.Addr, req.Quantity)
+}
+
+// Unmapped addresses are answered with an illegal data address exception
+func readBits(bank map[uint16]bool, addr, quantity uint16) ([]bool, error) {
+	res := make([]bool, quantity)
+	for i := range res {
+		v, got := bank[addr+uint16(i)]
+		if !got {
+			return nil, modbus.ErrIllegalDataAddress
+		}
+		res[i] = v
+	}
+	return res, nil
+}
+
+func readRegisters(bank map[uint16]uint16, addr, quantity uint16) ([]uint16, error) {
+	res := make([]uint16, quantity)
+	for i := range res {
+		v, got := bank[addr+uint16(i)]
+		if !got {
+			return nil, modbus.ErrIllegalDataAddress
+		}
+		res[i] = v
+	}
+	return res, nil
+}
+
+// DoCommand supports {"setpoints": true} to read the setpoints written by the masters
+func (s *modbusServer) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	if _, got := cmd["setpoints"]; got {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		setpoints := map[string]interface{}{}
+		for name, value := range s.setpoints {
+			setpoints[name] = value
+		}
+		return map[string]interface{}{"setpoints": setpoints}, nil
+	}
+	return nil, fmt.Errorf("DoCommand not implemented")
+}
+
+func (s *modbusServer) Close(ctx context.Context) error {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.workers.Wait()
+	return s.server.Stop()
+}
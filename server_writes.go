@@ -0,0 +1,257 @@
+package viammodbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/simonvetter/modbus"
+	toggleswitch "go.viam.com/rdk/components/switch"
+	"go.viam.com/rdk/resource"
+)
+
+// Actions triggered when a master writes a coil or holding register of the server
+const (
+	serverActionDoCommand   = "do_command"
+	serverActionSetPosition = "set_position"
+	serverActionSetpoint    = "setpoint"
+)
+
+// Time allowed for the action of a write before the master gets an exception
+const serverActionTimeout = 5 * time.Second
+
+// A coil or holding register that triggers an action when written by a master
+type serverWriteConfig struct {
+	Name     string                 `json:"name"`
+	Type     string                 `json:"type"`
+	Offset   int                    `json:"offset"`
+	DataType string                 `json:"data_type"`
+	Scale    float64                `json:"scale"`
+	Min      *float64               `json:"min"`
+	Max      *float64               `json:"max"`
+	Initial  float64                `json:"initial"`
+	Action   string                 `json:"action"`
+	Target   string                 `json:"target"`
+	Command  map[string]interface{} `json:"command"`
+	ValueKey string                 `json:"value_key"`
+}
+
+func (w *serverWriteConfig) point() *pointConfig {
+	return &pointConfig{Type: w.Type, Offset: w.Offset, DataType: w.DataType, Scale: w.Scale}
+}
+
+func (w *serverWriteConfig) validate(name string) error {
+	if err := w.point().validate(name, true); err != nil {
+		return err
+	}
+	if w.Min != nil && w.Max != nil && *w.Min > *w.Max {
+		return fmt.Errorf("%s min %v is greater than max %v", name, *w.Min, *w.Max)
+	}
+	switch w.Action {
+	case serverActionDoCommand:
+		if w.Target == "" {
+			return fmt.Errorf("target is required for action %v in %s", w.Action, name)
+		}
+	case serverActionSetPosition:
+		if w.Target == "" {
+			return fmt.Errorf("target is required for action %v in %s", w.Action, name)
+		}
+		if w.Command != nil || w.ValueKey != "" {
+			return fmt.Errorf("command and value_key are only supported for action %v in %s", serverActionDoCommand, name)
+		}
+	case serverActionSetpoint:
+		if w.Name == "" {
+			return fmt.Errorf("name is required for action %v in %s", w.Action, name)
+		}
+		if w.Target != "" || w.Command != nil || w.ValueKey != "" {
+			return fmt.Errorf("target, command and value_key are not supported for action %v in %s", w.Action, name)
+		}
+	default:
+		return fmt.Errorf("action must be one of %v, %v or %v in %s, got %q",
+			serverActionDoCommand, serverActionSetPosition, serverActionSetpoint, name, w.Action)
+	}
+	return nil
+}
+
+// Label of the write used in log messages
+func (w *serverWriteConfig) label() string {
+	if w.Name != "" {
+		return w.Name
+	}
+	return fmt.Sprintf("%v %d", w.Type, w.Offset)
+}
+
+// Indexes the writes by address, resolves their targets and serves their initial values
+func (s *modbusServer) setupWrites(deps resource.Dependencies, writes []serverWriteConfig) error {
+	for i := range writes {
+		w := &writes[i]
+		if w.Target != "" {
+			target, err := dependencyByName(deps, w.Target)
+			if err != nil {
+				return err
+			}
+			if _, ok := target.(toggleswitch.Switch); w.Action == serverActionSetPosition && !ok {
+				return fmt.Errorf("target %q of %s is not a switch", w.Target, w.label())
+			}
+			s.targets[w.Target] = target
+		}
+
+		if s.writes[w.Type] == nil {
+			s.writes[w.Type] = map[uint16]*serverWriteConfig{}
+		}
+		p := w.point()
+		for addr := p.Offset; addr < p.Offset+p.width(); addr++ {
+			s.writes[w.Type][uint16(addr)] = w
+		}
+
+		if err := s.storeWrite(w, w.Initial); err != nil {
+			return fmt.Errorf("invalid initial value of %s: %w", w.label(), err)
+		}
+	}
+	return nil
+}
+
+// Decodes the values written by a master, validates them and runs the actions. Nothing is
+// run unless every written address belongs to a write and every value is in range.
+func (s *modbusServer) handleWrite(table string, addr uint16, args []uint16) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	var writes []*serverWriteConfig
+	var values []float64
+	for i := 0; i < len(args); {
+		w, got := s.writes[table][addr+uint16(i)]
+		if !got {
+			return modbus.ErrIllegalDataAddress
+		}
+		p := w.point()
+		start := int(addr) + i
+		if start != p.Offset || i+p.width() > len(args) {
+			// Only part of a 32 bit value is written
+			return modbus.ErrIllegalDataAddress
+		}
+		value, err := s.decodeWrite(w, args[i:i+p.width()])
+		if err != nil {
+			return err
+		}
+		writes = append(writes, w)
+		values = append(values, value)
+		i += p.width()
+	}
+
+	for i, w := range writes {
+		if err := s.runWrite(w, values[i]); err != nil {
+			s.logger.Errorf("Failed to handle write of %v to %s: %v", values[i], w.label(), err)
+			return modbus.ErrServerDeviceFailure
+		}
+		if err := s.storeWrite(w, values[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the scaled value of the registers, or an illegal data value error when it is out of range
+func (s *modbusServer) decodeWrite(w *serverWriteConfig, regs []uint16) (float64, error) {
+	p := w.point()
+	value := float64(regs[0])
+	if !p.isBit() {
+		value = decodeRegisters(p.DataType, regs, s.endianness, s.wordOrder) * p.scale()
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		s.logger.Warnf("Rejected write of %v to %s", value, w.label())
+		return 0, modbus.ErrIllegalDataValue
+	}
+	if (w.Min != nil && value < *w.Min) || (w.Max != nil && value > *w.Max) {
+		s.logger.Warnf("Rejected write of %v to %s, value out of range", value, w.label())
+		return 0, modbus.ErrIllegalDataValue
+	}
+	if w.Action == serverActionSetPosition && (value < 0 || value != math.Trunc(value) || value > math.MaxUint32) {
+		s.logger.Warnf("Rejected write of %v to %s, not a switch position", value, w.label())
+		return 0, modbus.ErrIllegalDataValue
+	}
+	return value, nil
+}
+
+func (s *modbusServer) runWrite(w *serverWriteConfig, value float64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), serverActionTimeout)
+	defer cancel()
+
+	switch w.Action {
+	case serverActionDoCommand:
+		cmd := map[string]interface{}{}
+		for k, v := range w.Command {
+			cmd[k] = v
+		}
+		key := w.ValueKey
+		if key == "" {
+			key = "value"
+		}
+		if w.Type == pointCoil {
+			cmd[key] = value != 0
+		} else {
+			cmd[key] = value
+		}
+		_, err := s.targets[w.Target].DoCommand(ctx, cmd)
+		return err
+	case serverActionSetPosition:
+		sw, ok := s.targets[w.Target].(toggleswitch.Switch)
+		if !ok {
+			return errors.New("target is not a switch")
+		}
+		return sw.SetPosition(ctx, uint32(value), nil)
+	}
+	return nil
+}
+
+// Serves the written value on reads and records setpoints
+func (s *modbusServer) storeWrite(w *serverWriteConfig, value float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p := w.point()
+	if p.isBit() {
+		s.coils[uint16(p.Offset)] = value != 0
+	} else {
+		regs, err := encodeRegisters(p.DataType, value/p.scale(), s.endianness, s.wordOrder)
+		if err != nil {
+			return err
+		}
+		for i, reg := range regs {
+			s.holding[uint16(p.Offset+i)] = reg
+		}
+	}
+	if w.Action == serverActionSetpoint {
+		s.setpoints[w.Name] = value
+	}
+	return nil
+}
+
+// Decodes registers in the configured byte and word order into the raw value of the data type
+func decodeRegisters(dataType string, regs []uint16, endianness modbus.Endianness, wordOrder modbus.WordOrder) float64 {
+	words := make([]uint16, len(regs))
+	copy(words, regs)
+	if endianness == modbus.LITTLE_ENDIAN {
+		for i, reg := range words {
+			words[i] = reg<<8 | reg>>8
+		}
+	}
+	bits := uint32(words[0])
+	if len(words) == 2 {
+		if wordOrder == modbus.LOW_WORD_FIRST {
+			words[0], words[1] = words[1], words[0]
+		}
+		bits = uint32(words[0])<<16 | uint32(words[1])
+	}
+	switch dataType {
+	case "float32":
+		return float64(math.Float32frombits(bits))
+	case "int32":
+		return float64(int32(bits))
+	case "int16":
+		return float64(int16(bits))
+	default:
+		return float64(bits)
+	}
+}
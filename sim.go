@@ -0,0 +1,407 @@
+package viammodbus
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/simonvetter/modbus"
+	"go.viam.com/rdk/logging"
+)
+
+// URL scheme of the simulated device
+const simScheme = "sim"
+
+const (
+	defaultSimUpdateMs = 100
+	defaultSimPeriodMs = 10000
+)
+
+// Waveform shapes of the simulated device
+const (
+	simShapeRamp       = "ramp"
+	simShapeSine       = "sine"
+	simShapeRandomWalk = "random_walk"
+	simShapeToggle     = "toggle"
+)
+
+// Register bank of the simulated device, read from the file of the sim:// url and the
+// simulation attribute of the client
+type simConfig struct {
+	UpdateMs  int           `json:"update_ms"`
+	Points    []simPoint    `json:"points"`
+	Waveforms []simWaveform `json:"waveforms"`
+	Faults    []simFault    `json:"faults"`
+}
+
+// A coil, discrete input or register seeded with a value
+type simPoint struct {
+	Type     string  `json:"type"`
+	Offset   int     `json:"offset"`
+	DataType string  `json:"data_type"`
+	Value    float64 `json:"value"`
+}
+
+// A coil, discrete input or register whose value changes over time
+type simWaveform struct {
+	Type     string  `json:"type"`
+	Offset   int     `json:"offset"`
+	DataType string  `json:"data_type"`
+	Shape    string  `json:"shape"`
+	Min      float64 `json:"min"`
+	Max      float64 `json:"max"`
+	PeriodMs int     `json:"period_ms"`
+	Step     float64 `json:"step"`
+}
+
+func (p *simPoint) point() *pointConfig {
+	return &pointConfig{Type: p.Type, Offset: p.Offset, DataType: p.DataType}
+}
+
+func (w *simWaveform) point() *pointConfig {
+	return &pointConfig{Type: w.Type, Offset: w.Offset, DataType: w.DataType}
+}
+
+// Returns the byte and word order the client applies, see setDecoding
+func simEncoding(endianness, wordOrder string) (modbus.Endianness, modbus.WordOrder) {
+	enc, err := GetEndianness(endianness)
+	if err != nil {
+		return modbus.BIG_ENDIAN, modbus.HIGH_WORD_FIRST
+	}
+	wo, err := GetWordOrder(wordOrder)
+	if err != nil {
+		return modbus.BIG_ENDIAN, modbus.HIGH_WORD_FIRST
+	}
+	return enc, wo
+}
+
+func isSimURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && u.Scheme == simScheme
+}
+
+// Loads the bank file of the sim:// url, if any, and appends the inline configuration
+func loadSimConfig(rawURL string, inline *simConfig) (*simConfig, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &simConfig{}
+	if path := u.Host + u.Path; path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read simulation file: %w", err)
+		}
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("failed to parse simulation file %s: %w", path, err)
+		}
+	}
+	if inline != nil {
+		if inline.UpdateMs != 0 {
+			cfg.UpdateMs = inline.UpdateMs
+		}
+		cfg.Points = append(cfg.Points, inline.Points...)
+		cfg.Waveforms = append(cfg.Waveforms, inline.Waveforms...)
+		cfg.Faults = append(cfg.Faults, inline.Faults...)
+	}
+	return cfg, cfg.validate()
+}
+
+func (cfg *simConfig) validate() error {
+	if cfg.UpdateMs < 0 {
+		return fmt.Errorf("simulation update_ms must be non-negative, got %d", cfg.UpdateMs)
+	}
+	for i, p := range cfg.Points {
+		name := fmt.Sprintf("simulation point %v", i)
+		if err := p.point().validate(name, false); err != nil {
+			return err
+		}
+		if !p.point().isBit() {
+			if _, err := registerBits(p.point().DataType, p.Value); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+	for i, w := range cfg.Waveforms {
+		name := fmt.Sprintf("simulation waveform %v", i)
+		if err := w.point().validate(name, false); err != nil {
+			return err
+		}
+		switch w.Shape {
+		case simShapeRamp, simShapeSine, simShapeRandomWalk, simShapeToggle:
+		default:
+			return fmt.Errorf("%s shape must be one of %v, %v, %v or %v, got %q",
+				name, simShapeRamp, simShapeSine, simShapeRandomWalk, simShapeToggle, w.Shape)
+		}
+		if w.point().isBit() && w.Shape != simShapeToggle {
+			return fmt.Errorf("%s shape must be %v for %v", name, simShapeToggle, w.Type)
+		}
+		if w.PeriodMs < 0 {
+			return fmt.Errorf("%s period_ms must be non-negative, got %d", name, w.PeriodMs)
+		}
+		if w.Min > w.Max {
+			return fmt.Errorf("%s min %v is greater than max %v", name, w.Min, w.Max)
+		}
+		if !w.point().isBit() {
+			for _, v := range []float64{w.Min, w.Max} {
+				if _, err := registerBits(w.point().DataType, v); err != nil {
+					return fmt.Errorf("%s: %w", name, err)
+				}
+			}
+		}
+	}
+	for i, f := range cfg.Faults {
+		if err := f.validate(fmt.Sprintf("simulation fault %v", i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// An in-process device answering requests from its register bank. Every unit id shares
+// the same bank.
+type simulator struct {
+	logger     logging.Logger
+	endianness modbus.Endianness
+	wordOrder  modbus.WordOrder
+
+	mu             sync.Mutex
+	coils          []bool
+	discreteInputs []bool
+	holding        []uint16
+	input          []uint16
+
+	waveforms []simWaveform
+	walks     []float64
+	faults    []simFault
+	start     time.Time
+
+	cancel  context.CancelFunc
+	workers sync.WaitGroup
+}
+
+func newSimulator(cfg *simConfig, endianness modbus.Endianness, wordOrder modbus.WordOrder, logger logging.Logger) (*simulator, error) {
+	s := &simulator{
+		logger:         logger,
+		endianness:     endianness,
+		wordOrder:      wordOrder,
+		coils:          make([]bool, 65536),
+		discreteInputs: make([]bool, 65536),
+		holding:        make([]uint16, 65536),
+		input:          make([]uint16, 65536),
+		waveforms:      cfg.Waveforms,
+		walks:          make([]float64, len(cfg.Waveforms)),
+		faults:         cfg.Faults,
+		start:          time.Now(),
+	}
+	for _, p := range cfg.Points {
+		if err := s.set(p.point(), p.Value); err != nil {
+			return nil, err
+		}
+	}
+	for i, w := range s.waveforms {
+		s.walks[i] = (w.Min + w.Max) / 2
+	}
+	s.updateWaveforms()
+
+	if len(s.waveforms) > 0 {
+		interval := defaultSimUpdateMs * time.Millisecond
+		if cfg.UpdateMs > 0 {
+			interval = time.Duration(cfg.UpdateMs) * time.Millisecond
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		s.cancel = cancel
+		s.workers.Add(1)
+		go s.run(ctx, interval)
+	}
+	return s, nil
+}
+
+func (s *simulator) Close() error {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.workers.Wait()
+	return nil
+}
+
+func (s *simulator) run(ctx context.Context, interval time.Duration) {
+	defer s.workers.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.updateWaveforms()
+		}
+	}
+}
+
+func (s *simulator) updateWaveforms() {
+	elapsed := float64(time.Since(s.start).Milliseconds())
+	for i, w := range s.waveforms {
+		period := float64(defaultSimPeriodMs)
+		if w.PeriodMs > 0 {
+			period = float64(w.PeriodMs)
+		}
+		var value float64
+		switch w.Shape {
+		case simShapeRamp:
+			value = w.Min + (w.Max-w.Min)*math.Mod(elapsed, period)/period
+		case simShapeSine:
+			value = (w.Min+w.Max)/2 + (w.Max-w.Min)/2*math.Sin(2*math.Pi*elapsed/period)
+		case simShapeRandomWalk:
+			step := w.Step
+			if step == 0 {
+				step = (w.Max - w.Min) / 100
+			}
+			s.walks[i] = math.Max(w.Min, math.Min(w.Max, s.walks[i]+(rand.Float64()*2-1)*step))
+			value = s.walks[i]
+		case simShapeToggle:
+			high := int64(elapsed/period)%2 == 1
+			value = w.Min
+			if w.point().isBit() {
+				value = boolToFloat(high)
+			} else if high {
+				value = w.Max
+			}
+		}
+		if err := s.set(w.point(), value); err != nil {
+			s.logger.Debugf("Failed to update simulated %v %d: %v", w.Type, w.Offset, err)
+		}
+	}
+}
+
+// Stores the raw value in the bank encoded in the byte and word order of the client
+func (s *simulator) set(p *pointConfig, value float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch p.Type {
+	case pointCoil:
+		s.coils[p.Offset] = value != 0
+		return nil
+	case pointDiscreteInput:
+		s.discreteInputs[p.Offset] = value != 0
+		return nil
+	}
+	regs, err := encodeRegisters(p.DataType, value, s.endianness, s.wordOrder)
+	if err != nil {
+		return err
+	}
+	bank := s.holding
+	if p.Type == pointInputRegister {
+		bank = s.input
+	}
+	copy(bank[p.Offset:], regs)
+	return nil
+}
+
+// Answers a request PDU from the bank, must be called with the lock held
+func (s *simulator) answer(req []byte) []byte {
+	if len(req) < 5 {
+		return exceptionPDU(req[0], modbus.ErrIllegalDataValue)
+	}
+	fc := req[0]
+	addr := int(binary.BigEndian.Uint16(req[1:3]))
+	arg := int(binary.BigEndian.Uint16(req[3:5]))
+
+	switch fc {
+	case 0x01, 0x02:
+		if arg < 1 || arg > 2000 {
+			return exceptionPDU(fc, modbus.ErrIllegalDataValue)
+		}
+		if addr+arg > 65536 {
+			return exceptionPDU(fc, modbus.ErrIllegalDataAddress)
+		}
+		bank := s.coils
+		if fc == 0x02 {
+			bank = s.discreteInputs
+		}
+		res := make([]byte, 2+(arg+7)/8)
+		res[0], res[1] = fc, byte(len(res)-2)
+		for i, b := range bank[addr : addr+arg] {
+			if b {
+				res[2+i/8] |= 1 << (i % 8)
+			}
+		}
+		return res
+	case 0x03, 0x04:
+		if arg < 1 || arg > 125 {
+			return exceptionPDU(fc, modbus.ErrIllegalDataValue)
+		}
+		if addr+arg > 65536 {
+			return exceptionPDU(fc, modbus.ErrIllegalDataAddress)
+		}
+		bank := s.holding
+		if fc == 0x04 {
+			bank = s.input
+		}
+		res := make([]byte, 2+2*arg)
+		res[0], res[1] = fc, byte(2*arg)
+		for i, reg := range bank[addr : addr+arg] {
+			binary.BigEndian.PutUint16(res[2+2*i:], reg)
+		}
+		return res
+	case 0x05:
+		if arg != 0xFF00 && arg != 0x0000 {
+			return exceptionPDU(fc, modbus.ErrIllegalDataValue)
+		}
+		s.coils[addr] = arg == 0xFF00
+		return req[:5]
+	case 0x06:
+		s.holding[addr] = uint16(arg)
+		return req[:5]
+	case 0x0F, 0x10:
+		if len(req) < 6 || len(req) != 6+int(req[5]) {
+			return exceptionPDU(fc, modbus.ErrIllegalDataValue)
+		}
+		if addr+arg > 65536 {
+			return exceptionPDU(fc, modbus.ErrIllegalDataAddress)
+		}
+		values := req[6:]
+		if fc == 0x0F {
+			if arg < 1 || arg > 1968 || len(values) != (arg+7)/8 {
+				return exceptionPDU(fc, modbus.ErrIllegalDataValue)
+			}
+			for i := 0; i < arg; i++ {
+				s.coils[addr+i] = values[i/8]&(1<<(i%8)) != 0
+			}
+		} else {
+			if arg < 1 || arg > 123 || len(values) != 2*arg {
+				return exceptionPDU(fc, modbus.ErrIllegalDataValue)
+			}
+			for i := 0; i < arg; i++ {
+				s.holding[addr+i] = binary.BigEndian.Uint16(values[2*i:])
+			}
+		}
+		return req[:5]
+	default:
+		return exceptionPDU(fc, modbus.ErrIllegalFunction)
+	}
+}
+
+var exceptionCodes = map[error]byte{
+	modbus.ErrIllegalFunction:         0x01,
+	modbus.ErrIllegalDataAddress:      0x02,
+	modbus.ErrIllegalDataValue:        0x03,
+	modbus.ErrServerDeviceFailure:     0x04,
+	modbus.ErrAcknowledge:             0x05,
+	modbus.ErrServerDeviceBusy:        0x06,
+	modbus.ErrMemoryParityError:       0x08,
+	modbus.ErrGWPathUnavailable:       0x0A,
+	modbus.ErrGWTargetFailedToRespond: 0x0B,
+}
+
+// Returns the exception response PDU for the function code
+func exceptionPDU(fc byte, err error) []byte {
+	return []byte{fc | 0x80, exceptionCodes[err]}
+}
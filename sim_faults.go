@@ -0,0 +1,200 @@
+package viammodbus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// Faults the simulated device injects
+const (
+	simFaultTimeout   = "timeout"
+	simFaultDrop      = "drop"
+	simFaultException = "exception"
+	simFaultCorrupt   = "corrupt"
+	simFaultDelay     = "delay"
+)
+
+// A fault injected into the requests matching the unit ids and address range while the
+// schedule is active
+type simFault struct {
+	Fault         string   `json:"fault"`
+	UnitIDs       []int    `json:"unit_ids"`
+	Type          string   `json:"type"`
+	Start         int      `json:"start"`
+	End           *int     `json:"end"`
+	Probability   *float64 `json:"probability"`
+	ExceptionCode int      `json:"exception_code"`
+	DelayMs       int      `json:"delay_ms"`
+
+	// Active for duration_ms of every every_ms, starting after_ms after the device starts
+	AfterMs    int `json:"after_ms"`
+	EveryMs    int `json:"every_ms"`
+	DurationMs int `json:"duration_ms"`
+}
+
+func (f *simFault) validate(name string) error {
+	switch f.Fault {
+	case simFaultTimeout, simFaultDrop, simFaultCorrupt:
+	case simFaultException:
+		if !validExceptionCode(f.ExceptionCode) {
+			return fmt.Errorf("%s exception_code must be one of 1, 2, 3, 4, 5, 6, 8, 10 or 11, got %d", name, f.ExceptionCode)
+		}
+	case simFaultDelay:
+		if f.DelayMs <= 0 {
+			return fmt.Errorf("%s delay_ms must be positive, got %d", name, f.DelayMs)
+		}
+	default:
+		return fmt.Errorf("%s fault must be one of %v, %v, %v, %v or %v, got %q", name,
+			simFaultTimeout, simFaultDrop, simFaultException, simFaultCorrupt, simFaultDelay, f.Fault)
+	}
+	if f.Fault != simFaultException && f.ExceptionCode != 0 {
+		return fmt.Errorf("%s exception_code is only supported for fault %v", name, simFaultException)
+	}
+	if f.Fault != simFaultDelay && f.DelayMs != 0 {
+		return fmt.Errorf("%s delay_ms is only supported for fault %v", name, simFaultDelay)
+	}
+	for _, id := range f.UnitIDs {
+		if id < 0 || id > 255 {
+			return fmt.Errorf("%s unit_ids must be between 0 and 255, got %d", name, id)
+		}
+	}
+	switch f.Type {
+	case "", pointCoil, pointDiscreteInput, pointHoldingRegister, pointInputRegister:
+	default:
+		return fmt.Errorf("%s type must be one of %v, %v, %v or %v, got %q",
+			name, pointCoil, pointDiscreteInput, pointHoldingRegister, pointInputRegister, f.Type)
+	}
+	if f.Start < 0 || f.Start > 65535 {
+		return fmt.Errorf("%s start must be between 0 and 65535, got %d", name, f.Start)
+	}
+	if f.End != nil && (*f.End < f.Start || *f.End > 65535) {
+		return fmt.Errorf("%s end must be between start and 65535, got %d", name, *f.End)
+	}
+	if f.Probability != nil && (*f.Probability < 0 || *f.Probability > 1) {
+		return fmt.Errorf("%s probability must be between 0 and 1, got %v", name, *f.Probability)
+	}
+	if f.AfterMs < 0 || f.EveryMs < 0 || f.DurationMs < 0 {
+		return fmt.Errorf("%s after_ms, every_ms and duration_ms must be non-negative", name)
+	}
+	if f.EveryMs > 0 && f.DurationMs > f.EveryMs {
+		return fmt.Errorf("%s duration_ms must not exceed every_ms", name)
+	}
+	return nil
+}
+
+func validExceptionCode(code int) bool {
+	for _, c := range exceptionCodes {
+		if int(c) == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns true if the schedule of the fault is active after the elapsed time
+func (f *simFault) active(elapsed time.Duration) bool {
+	ms := int(elapsed.Milliseconds()) - f.AfterMs
+	if ms < 0 {
+		return false
+	}
+	if f.EveryMs > 0 {
+		ms %= f.EveryMs
+	}
+	return f.DurationMs == 0 || ms < f.DurationMs
+}
+
+// Returns true if the request addresses the unit id, table and address range of the fault
+func (f *simFault) matches(unitID uint8, req []byte) bool {
+	if len(f.UnitIDs) > 0 {
+		found := false
+		for _, id := range f.UnitIDs {
+			found = found || id == int(unitID)
+		}
+		if !found {
+			return false
+		}
+	}
+	table, first, last, ok := requestRange(req)
+	if !ok {
+		return f.Type == "" && f.Start == 0 && f.End == nil
+	}
+	if f.Type != "" && f.Type != table {
+		return false
+	}
+	end := 65535
+	if f.End != nil {
+		end = *f.End
+	}
+	return first <= end && last >= f.Start
+}
+
+// Returns the table and the first and last address of a request
+func requestRange(req []byte) (string, int, int, bool) {
+	if len(req) < 5 {
+		return "", 0, 0, false
+	}
+	addr := int(binary.BigEndian.Uint16(req[1:3]))
+	quantity := int(binary.BigEndian.Uint16(req[3:5]))
+	switch req[0] {
+	case 0x01, 0x0F:
+		return pointCoil, addr, addr + quantity - 1, true
+	case 0x05:
+		return pointCoil, addr, addr, true
+	case 0x02:
+		return pointDiscreteInput, addr, addr + quantity - 1, true
+	case 0x03, 0x10:
+		return pointHoldingRegister, addr, addr + quantity - 1, true
+	case 0x06:
+		return pointHoldingRegister, addr, addr, true
+	case 0x04:
+		return pointInputRegister, addr, addr + quantity - 1, true
+	}
+	return "", 0, 0, false
+}
+
+// Returns the first active fault matching the request, if any
+func (s *simulator) fault(unitID uint8, req []byte) *simFault {
+	elapsed := time.Since(s.start)
+	for i := range s.faults {
+		f := &s.faults[i]
+		if !f.active(elapsed) || !f.matches(unitID, req) {
+			continue
+		}
+		if f.Probability != nil && rand.Float64() >= *f.Probability {
+			continue
+		}
+		return f
+	}
+	return nil
+}
+
+// Answers the request, injecting the matching fault
+func (s *simulator) exchange(unitID uint8, req []byte) ([]byte, error) {
+	f := s.fault(unitID, req)
+	if f != nil {
+		s.logger.Debugf("Injecting simulated %v fault for unit id %d function code %d", f.Fault, unitID, req[0])
+		switch f.Fault {
+		case simFaultTimeout:
+			return nil, errNoResponse
+		case simFaultDrop:
+			return nil, errDropConnection
+		case simFaultException:
+			return []byte{req[0] | 0x80, byte(f.ExceptionCode)}, nil
+		case simFaultDelay:
+			time.Sleep(time.Duration(f.DelayMs) * time.Millisecond)
+		}
+	}
+
+	s.mu.Lock()
+	res := s.answer(req)
+	s.mu.Unlock()
+
+	if f != nil && f.Fault == simFaultCorrupt {
+		// Answer with a function code that is neither the request's nor its exception, data
+		// bytes are left alone as a changed value would still be a valid response
+		res[0] ^= 0x40
+	}
+	return res, nil
+}
@@ -0,0 +1,486 @@
+package viammodbus
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+
+	"github.com/simonvetter/modbus"
+	"go.viam.com/rdk/components/sensor"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+)
+
+var SunSpecSensorModel = NamespaceFamily.WithModel("sunspec")
+
+// SunSpec well known base addresses in the order they are probed
+var sunSpecBaseAddresses = []uint16{40000, 0, 50000}
+
+const (
+	sunSpecMarker  = "SunS"
+	sunSpecEndID   = 0xFFFF
+	sunSpecMaxRead = 125
+	// Upper bound for the model chain to guard against devices that never return the end model
+	sunSpecMaxModels = 64
+)
+
+func init() {
+	resource.RegisterComponent(
+		sensor.API,
+		SunSpecSensorModel,
+		resource.Registration[sensor.Sensor, *sunSpecSensorConfig]{
+			Constructor: newSunSpecSensor,
+		})
+}
+
+type sunSpecSensorConfig struct {
+	ModbusClient string `json:"modbus_connection_name"`
+	UnitID       int    `json:"unit_id"`
+	BaseAddress  *int   `json:"base_address"`
+}
+
+func (cfg *sunSpecSensorConfig) Validate(path string) ([]string, []string, error) {
+	if cfg.ModbusClient == "" {
+		return nil, nil, resource.NewConfigValidationFieldRequiredError(path, "modbus_connection_name")
+	}
+	if cfg.UnitID != 0 && (cfg.UnitID < 1 || cfg.UnitID > 247) {
+		return nil, nil, fmt.Errorf("unit_id must be between 1 and 247 or removed, got %d", cfg.UnitID)
+	}
+	if cfg.BaseAddress != nil && (*cfg.BaseAddress < 0 || *cfg.BaseAddress > math.MaxUint16) {
+		return nil, nil, fmt.Errorf("base_address must be between 0 and %d, got %d", math.MaxUint16, *cfg.BaseAddress)
+	}
+	return []string{cfg.ModbusClient}, nil, nil
+}
+
+type sunSpecSensor struct {
+	resource.AlwaysRebuild
+	resource.Named
+	mu     sync.Mutex
+	logger logging.Logger
+	mc     *modbusClient
+	unitID uint8
+	bases  []uint16
+
+	// Discovered lazily on the first reading
+	base   *uint16
+	models []sunSpecModelInstance
+}
+
+// A model found while walking the model chain
+type sunSpecModelInstance struct {
+	ID      uint16
+	Address uint16
+	Length  uint16
+}
+
+func newSunSpecSensor(ctx context.Context, deps resource.Dependencies, conf resource.Config, logger logging.Logger) (sensor.Sensor, error) {
+	newConf, err := resource.NativeConfig[*sunSpecSensorConfig](conf)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &sunSpecSensor{
+		Named:  conf.ResourceName().AsNamed(),
+		logger: logger,
+		unitID: 1,
+		bases:  sunSpecBaseAddresses,
+	}
+	if newConf.UnitID > 0 {
+		s.unitID = uint8(newConf.UnitID)
+	}
+	if newConf.BaseAddress != nil {
+		s.bases = []uint16{uint16(*newConf.BaseAddress)}
+	}
+
+	client, err := GlobalClientRegistry.Get(newConf.ModbusClient)
+	if err != nil {
+		return nil, err
+	}
+	s.mc = client
+	return s, nil
+}
+
+// Returns the decoded points of all supported models found on the device
+func (s *sunSpecSensor) Readings(ctx context.Context, extra map[string]interface{}) (map[string]interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.base == nil {
+		if err := s.discover(); err != nil {
+			return nil, err
+		}
+	}
+
+	results := map[string]interface{}{
+		"sunspec_base_address": int(*s.base),
+	}
+	ids := []string{}
+	groupCount := map[string]int{}
+	for _, m := range s.models {
+		ids = append(ids, fmt.Sprint(m.ID))
+		def, got := sunSpecModelDefinitions[m.ID]
+		if !got {
+			continue
+		}
+		regs, err := s.readRegisters(m.Address, m.Length)
+		if err != nil {
+			// The model chain may have changed after a firmware update
+			s.base = nil
+			return nil, err
+		}
+
+		groupCount[def.group]++
+		prefix := def.group
+		if groupCount[def.group] > 1 {
+			prefix = fmt.Sprintf("%s_%d", def.group, groupCount[def.group])
+		}
+		decodeSunSpecModel(def, regs, prefix, results)
+	}
+	results["sunspec_models"] = strings.Join(ids, ",")
+
+	return results, nil
+}
+
+// Scans the base addresses for the SunS marker and walks the model chain
+func (s *sunSpecSensor) discover() error {
+	for _, base := range s.bases {
+		marker, err := s.mc.ReadRawBytes(base, 4, modbus.HOLDING_REGISTER, s.unitID)
+		if err != nil || string(marker) != sunSpecMarker {
+			s.logger.Debugf("No SunSpec marker at base address %d", base)
+			continue
+		}
+
+		models := []sunSpecModelInstance{}
+		addr := base + 2
+		for i := 0; i < sunSpecMaxModels; i++ {
+			header, err := s.readRegisters(addr, 2)
+			if err != nil {
+				return fmt.Errorf("failed to read SunSpec model header at %d: %w", addr, err)
+			}
+			if header[0] == sunSpecEndID {
+				b := base
+				s.base = &b
+				s.models = models
+				s.logger.Infof("Found %d SunSpec models at base address %d", len(models), base)
+				return nil
+			}
+			models = append(models, sunSpecModelInstance{ID: header[0], Address: addr + 2, Length: header[1]})
+			addr += 2 + header[1]
+		}
+		return fmt.Errorf("SunSpec model chain at base address %d has no end marker", base)
+	}
+	return errors.New("no SunSpec marker found on device")
+}
+
+func (s *sunSpecSensor) readRegisters(addr, length uint16) ([]uint16, error) {
+	regs := make([]uint16, 0, length)
+	for length > 0 {
+		n := min(length, sunSpecMaxRead)
+		// SunSpec registers are big endian whatever the endianness of the client
+		b, err := s.mc.ReadRawBytes(addr, 2*n, modbus.HOLDING_REGISTER, s.unitID)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i+1 < len(b); i += 2 {
+			regs = append(regs, binary.BigEndian.Uint16(b[i:]))
+		}
+		addr += n
+		length -= n
+	}
+	return regs, nil
+}
+
+// DoCommand supports {"rescan": true} to rediscover the model chain
+func (s *sunSpecSensor) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	if _, got := cmd["rescan"]; got {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.base = nil
+		if err := s.discover(); err != nil {
+			return nil, err
+		}
+		ids := []interface{}{}
+		for _, m := range s.models {
+			ids = append(ids, int(m.ID))
+		}
+		return map[string]interface{}{"base_address": int(*s.base), "models": ids}, nil
+	}
+	return nil, fmt.Errorf("DoCommand not implemented")
+}
+
+func (s *sunSpecSensor) Close(ctx context.Context) error {
+	return nil
+}
+
+// SunSpec point encodings
+type sunSpecPointType int
+
+const (
+	sunSpecUint16 sunSpecPointType = iota
+	sunSpecInt16
+	sunSpecAcc32
+	sunSpecFloat32
+	sunSpecEnum16
+	sunSpecBitfield16
+	sunSpecBitfield32
+	sunSpecString
+)
+
+type sunSpecPoint struct {
+	name   string
+	offset int
+	kind   sunSpecPointType
+	// Register offset of the scale factor, -1 if the point is not scaled
+	sf int
+	// Register count for strings
+	length int
+}
+
+type sunSpecModelDefinition struct {
+	group  string
+	points []sunSpecPoint
+}
+
+func decodeSunSpecModel(def sunSpecModelDefinition, regs []uint16, prefix string, results map[string]interface{}) {
+	for _, p := range def.points {
+		width := 1
+		switch p.kind {
+		case sunSpecAcc32, sunSpecFloat32, sunSpecBitfield32:
+			width = 2
+		case sunSpecString:
+			width = p.length
+		}
+		if p.offset+width > len(regs) {
+			continue
+		}
+		r := regs[p.offset : p.offset+width]
+
+		var value float64
+		switch p.kind {
+		case sunSpecString:
+			b := make([]byte, 0, 2*len(r))
+			for _, reg := range r {
+				b = append(b, byte(reg>>8), byte(reg))
+			}
+			results[prefix+"_"+p.name] = strings.TrimRight(string(b), "\x00 ")
+			continue
+		case sunSpecUint16:
+			if r[0] == 0xFFFF {
+				continue
+			}
+			value = float64(r[0])
+		case sunSpecInt16:
+			if r[0] == 0x8000 {
+				continue
+			}
+			value = float64(int16(r[0]))
+		case sunSpecEnum16, sunSpecBitfield16:
+			if r[0] == 0xFFFF {
+				continue
+			}
+			results[prefix+"_"+p.name] = int(r[0])
+			continue
+		case sunSpecBitfield32:
+			v := uint32(r[0])<<16 | uint32(r[1])
+			if v == 0xFFFFFFFF {
+				continue
+			}
+			results[prefix+"_"+p.name] = v
+			continue
+		case sunSpecAcc32:
+			value = float64(uint32(r[0])<<16 | uint32(r[1]))
+		case sunSpecFloat32:
+			f := math.Float32frombits(uint32(r[0])<<16 | uint32(r[1]))
+			if math.IsNaN(float64(f)) {
+				continue
+			}
+			value = float64(f)
+		}
+
+		if p.sf >= 0 {
+			if p.sf >= len(regs) || regs[p.sf] == 0x8000 {
+				continue
+			}
+			value *= math.Pow10(int(int16(regs[p.sf])))
+		}
+		results[prefix+"_"+p.name] = value
+	}
+}
+
+func scaled(name string, offset int, kind sunSpecPointType, sf int) sunSpecPoint {
+	return sunSpecPoint{name: name, offset: offset, kind: kind, sf: sf}
+}
+
+func unscaled(name string, offset int, kind sunSpecPointType) sunSpecPoint {
+	return sunSpecPoint{name: name, offset: offset, kind: kind, sf: -1}
+}
+
+func float32Points(names ...string) []sunSpecPoint {
+	points := []sunSpecPoint{}
+	for i, name := range names {
+		if name == "" {
+			continue
+		}
+		points = append(points, unscaled(name, 2*i, sunSpecFloat32))
+	}
+	return points
+}
+
+// Common model (1)
+var sunSpecCommon = sunSpecModelDefinition{
+	group: "common",
+	points: []sunSpecPoint{
+		{name: "Mn", offset: 0, kind: sunSpecString, sf: -1, length: 16},
+		{name: "Md", offset: 16, kind: sunSpecString, sf: -1, length: 16},
+		{name: "Opt", offset: 32, kind: sunSpecString, sf: -1, length: 8},
+		{name: "Vr", offset: 40, kind: sunSpecString, sf: -1, length: 8},
+		{name: "SN", offset: 48, kind: sunSpecString, sf: -1, length: 16},
+		unscaled("DA", 64, sunSpecUint16),
+	},
+}
+
+// Inverter models with integer values and scale factors (101, 102, 103)
+var sunSpecInverter = sunSpecModelDefinition{
+	group: "inverter",
+	points: []sunSpecPoint{
+		scaled("A", 0, sunSpecUint16, 4),
+		scaled("AphA", 1, sunSpecUint16, 4),
+		scaled("AphB", 2, sunSpecUint16, 4),
+		scaled("AphC", 3, sunSpecUint16, 4),
+		scaled("PPVphAB", 5, sunSpecUint16, 11),
+		scaled("PPVphBC", 6, sunSpecUint16, 11),
+		scaled("PPVphCA", 7, sunSpecUint16, 11),
+		scaled("PhVphA", 8, sunSpecUint16, 11),
+		scaled("PhVphB", 9, sunSpecUint16, 11),
+		scaled("PhVphC", 10, sunSpecUint16, 11),
+		scaled("W", 12, sunSpecInt16, 13),
+		scaled("Hz", 14, sunSpecUint16, 15),
+		scaled("VA", 16, sunSpecInt16, 17),
+		scaled("VAr", 18, sunSpecInt16, 19),
+		scaled("PF", 20, sunSpecInt16, 21),
+		scaled("WH", 22, sunSpecAcc32, 24),
+		scaled("DCA", 25, sunSpecUint16, 26),
+		scaled("DCV", 27, sunSpecUint16, 28),
+		scaled("DCW", 29, sunSpecInt16, 30),
+		scaled("TmpCab", 31, sunSpecInt16, 35),
+		scaled("TmpSnk", 32, sunSpecInt16, 35),
+		scaled("TmpTrns", 33, sunSpecInt16, 35),
+		scaled("TmpOt", 34, sunSpecInt16, 35),
+		unscaled("St", 36, sunSpecEnum16),
+		unscaled("StVnd", 37, sunSpecEnum16),
+		unscaled("Evt1", 38, sunSpecBitfield32),
+		unscaled("Evt2", 40, sunSpecBitfield32),
+	},
+}
+
+// Inverter models with float values (111, 112, 113)
+var sunSpecInverterFloat = sunSpecModelDefinition{
+	group: "inverter",
+	points: append(float32Points(
+		"A", "AphA", "AphB", "AphC", "PPVphAB", "PPVphBC", "PPVphCA", "PhVphA", "PhVphB", "PhVphC",
+		"W", "Hz", "VA", "VAr", "PF", "WH", "DCA", "DCV", "DCW", "TmpCab", "TmpSnk", "TmpTrns", "TmpOt",
+	),
+		unscaled("St", 46, sunSpecEnum16),
+		unscaled("StVnd", 47, sunSpecEnum16),
+		unscaled("Evt1", 48, sunSpecBitfield32),
+		unscaled("Evt2", 50, sunSpecBitfield32),
+	),
+}
+
+// Meter models with integer values and scale factors (201, 202, 203, 204)
+var sunSpecMeter = sunSpecModelDefinition{
+	group: "meter",
+	points: []sunSpecPoint{
+		scaled("A", 0, sunSpecInt16, 4),
+		scaled("AphA", 1, sunSpecInt16, 4),
+		scaled("AphB", 2, sunSpecInt16, 4),
+		scaled("AphC", 3, sunSpecInt16, 4),
+		scaled("PhV", 5, sunSpecInt16, 13),
+		scaled("PhVphA", 6, sunSpecInt16, 13),
+		scaled("PhVphB", 7, sunSpecInt16, 13),
+		scaled("PhVphC", 8, sunSpecInt16, 13),
+		scaled("PPV", 9, sunSpecInt16, 13),
+		scaled("PPVphAB", 10, sunSpecInt16, 13),
+		scaled("PPVphBC", 11, sunSpecInt16, 13),
+		scaled("PPVphCA", 12, sunSpecInt16, 13),
+		scaled("Hz", 14, sunSpecInt16, 15),
+		scaled("W", 16, sunSpecInt16, 20),
+		scaled("WphA", 17, sunSpecInt16, 20),
+		scaled("WphB", 18, sunSpecInt16, 20),
+		scaled("WphC", 19, sunSpecInt16, 20),
+		scaled("VA", 21, sunSpecInt16, 25),
+		scaled("VAphA", 22, sunSpecInt16, 25),
+		scaled("VAphB", 23, sunSpecInt16, 25),
+		scaled("VAphC", 24, sunSpecInt16, 25),
+		scaled("VAR", 26, sunSpecInt16, 30),
+		scaled("VARphA", 27, sunSpecInt16, 30),
+		scaled("VARphB", 28, sunSpecInt16, 30),
+		scaled("VARphC", 29, sunSpecInt16, 30),
+		scaled("PF", 31, sunSpecInt16, 35),
+		scaled("PFphA", 32, sunSpecInt16, 35),
+		scaled("PFphB", 33, sunSpecInt16, 35),
+		scaled("PFphC", 34, sunSpecInt16, 35),
+		scaled("TotWhExp", 36, sunSpecAcc32, 52),
+		scaled("TotWhExpPhA", 38, sunSpecAcc32, 52),
+		scaled("TotWhExpPhB", 40, sunSpecAcc32, 52),
+		scaled("TotWhExpPhC", 42, sunSpecAcc32, 52),
+		scaled("TotWhImp", 44, sunSpecAcc32, 52),
+		scaled("TotWhImpPhA", 46, sunSpecAcc32, 52),
+		scaled("TotWhImpPhB", 48, sunSpecAcc32, 52),
+		scaled("TotWhImpPhC", 50, sunSpecAcc32, 52),
+	},
+}
+
+// Meter models with float values (211, 212, 213, 214)
+var sunSpecMeterFloat = sunSpecModelDefinition{
+	group: "meter",
+	points: float32Points(
+		"A", "AphA", "AphB", "AphC", "PhV", "PhVphA", "PhVphB", "PhVphC", "PPV", "PPVphAB", "PPVphBC", "PPVphCA",
+		"Hz", "W", "WphA", "WphB", "WphC", "VA", "VAphA", "VAphB", "VAphC", "VAR", "VARphA", "VARphB", "VARphC",
+		"PF", "PFphA", "PFphB", "PFphC", "TotWhExp", "TotWhExpPhA", "TotWhExpPhB", "TotWhExpPhC",
+		"TotWhImp", "TotWhImpPhA", "TotWhImpPhB", "TotWhImpPhC",
+	),
+}
+
+// Basic storage control model (124)
+var sunSpecStorage = sunSpecModelDefinition{
+	group: "storage",
+	points: []sunSpecPoint{
+		scaled("WChaMax", 0, sunSpecUint16, 16),
+		scaled("WChaGra", 1, sunSpecUint16, 17),
+		scaled("WDisChaGra", 2, sunSpecUint16, 17),
+		unscaled("StorCtl_Mod", 3, sunSpecBitfield16),
+		scaled("VAChaMax", 4, sunSpecUint16, 18),
+		scaled("MinRsvPct", 5, sunSpecUint16, 19),
+		scaled("ChaState", 6, sunSpecUint16, 20),
+		scaled("StorAval", 7, sunSpecUint16, 21),
+		scaled("InBatV", 8, sunSpecUint16, 22),
+		unscaled("ChaSt", 9, sunSpecEnum16),
+		scaled("OutWRte", 10, sunSpecInt16, 23),
+		scaled("InWRte", 11, sunSpecInt16, 23),
+		unscaled("ChaGriSet", 15, sunSpecEnum16),
+	},
+}
+
+var sunSpecModelDefinitions = map[uint16]sunSpecModelDefinition{
+	1:   sunSpecCommon,
+	101: sunSpecInverter,
+	102: sunSpecInverter,
+	103: sunSpecInverter,
+	111: sunSpecInverterFloat,
+	112: sunSpecInverterFloat,
+	113: sunSpecInverterFloat,
+	124: sunSpecStorage,
+	201: sunSpecMeter,
+	202: sunSpecMeter,
+	203: sunSpecMeter,
+	204: sunSpecMeter,
+	211: sunSpecMeterFloat,
+	212: sunSpecMeterFloat,
+	213: sunSpecMeterFloat,
+	214: sunSpecMeterFloat,
+}
@@ -0,0 +1,53 @@
+package viammodbus
+
+import (
+	"testing"
+
+	"github.com/simonvetter/modbus"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/test"
+)
+
+// Serves holding registers from a map, registers that are not in it read as 0
+type registerHandler struct {
+	modbus.RequestHandler
+	registers map[uint16]uint16
+}
+
+func (h *registerHandler) HandleHoldingRegisters(req *modbus.HoldingRegistersRequest) ([]uint16, error) {
+	res := make([]uint16, req.Quantity)
+	for i := range res {
+		res[i] = h.registers[req.Addr+uint16(i)]
+	}
+	return res, nil
+}
+
+func TestSunSpecDiscoverLittleEndianClient(t *testing.T) {
+	const base = 40000
+	handler := &registerHandler{registers: map[uint16]uint16{
+		// SunS marker
+		base: 0x5375, base + 1: 0x6E53,
+		// Common model of 66 registers, manufacturer "Acme"
+		base + 2: 1, base + 3: 66, base + 4: 0x4163, base + 5: 0x6D65,
+		// End of the model chain
+		base + 70: sunSpecEndID,
+	}}
+	addr := freeAddr(t)
+	server, err := modbus.NewServer(&modbus.ServerConfiguration{URL: "tcp://" + addr, MaxClients: 1}, handler)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, server.Start(), test.ShouldBeNil)
+	t.Cleanup(func() { server.Stop() })
+
+	mc := newTestClient(t, "tcp://"+addr)
+	test.That(t, mc.client.SetEncoding(modbus.LITTLE_ENDIAN, modbus.LOW_WORD_FIRST), test.ShouldBeNil)
+
+	s := &sunSpecSensor{logger: logging.NewTestLogger(t), mc: mc, unitID: 1, bases: []uint16{base}}
+	// The marker and model headers are big endian whatever the endianness of the client
+	test.That(t, s.discover(), test.ShouldBeNil)
+	test.That(t, *s.base, test.ShouldEqual, uint16(base))
+	test.That(t, s.models, test.ShouldResemble, []sunSpecModelInstance{{ID: 1, Address: base + 4, Length: 66}})
+
+	regs, err := s.readRegisters(base+4, 2)
+	test.That(t, err, test.ShouldBeNil)
+	test.That(t, regs, test.ShouldResemble, []uint16{0x4163, 0x6D65})
+}
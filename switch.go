@@ -0,0 +1,165 @@
+package viammodbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	toggleswitch "go.viam.com/rdk/components/switch"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+)
+
+var ModbusSwitchModel = NamespaceFamily.WithModel("switch")
+
+func init() {
+	resource.RegisterComponent(
+		toggleswitch.API,
+		ModbusSwitchModel,
+		resource.Registration[toggleswitch.Switch, *switchConfig]{
+			Constructor: newModbusSwitch,
+		})
+}
+
+type switchConfig struct {
+	ModbusClient string           `json:"modbus_connection_name"`
+	UnitID       int              `json:"unit_id"`
+	Write        *pointConfig     `json:"write"`
+	Read         *pointConfig     `json:"read"`
+	Positions    []switchPosition `json:"positions"`
+}
+
+// A switch position and the value written for it
+type switchPosition struct {
+	Label string `json:"label"`
+	Value int    `json:"value"`
+}
+
+// Positions of a switch backed by a single coil
+var defaultCoilPositions = []switchPosition{{Label: "off", Value: 0}, {Label: "on", Value: 1}}
+
+func (cfg *switchConfig) Validate(path string) ([]string, []string, error) {
+	if cfg.ModbusClient == "" {
+		return nil, nil, resource.NewConfigValidationFieldRequiredError(path, "modbus_connection_name")
+	}
+	if _, err := unitIDOrDefault(cfg.UnitID); err != nil {
+		return nil, nil, err
+	}
+	if cfg.Write == nil {
+		return nil, nil, resource.NewConfigValidationFieldRequiredError(path, "write")
+	}
+	if err := cfg.Write.validate("write", true); err != nil {
+		return nil, nil, err
+	}
+	if cfg.Read != nil {
+		if err := cfg.Read.validate("read", false); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if cfg.Write.Type == pointHoldingRegister && len(cfg.Positions) == 0 {
+		return nil, nil, errors.New("positions are required when writing a holding register")
+	}
+	if cfg.Write.Type == pointCoil && len(cfg.Positions) > 2 {
+		return nil, nil, fmt.Errorf("a switch writing a coil supports 2 positions, got %d", len(cfg.Positions))
+	}
+	values := map[int]bool{}
+	for i, p := range cfg.Positions {
+		if values[p.Value] {
+			return nil, nil, fmt.Errorf("value %d of position %d is used more than once", p.Value, i)
+		}
+		values[p.Value] = true
+		if cfg.Write.Type == pointCoil && p.Value != 0 && p.Value != 1 {
+			return nil, nil, fmt.Errorf("value of position %d must be 0 or 1 for a coil, got %d", i, p.Value)
+		}
+	}
+	return []string{cfg.ModbusClient}, nil, nil
+}
+
+type modbusSwitch struct {
+	resource.AlwaysRebuild
+	resource.Named
+	mu        sync.Mutex
+	logger    logging.Logger
+	mc        *modbusClient
+	unitID    uint8
+	write     *pointConfig
+	read      *pointConfig
+	positions []switchPosition
+}
+
+func newModbusSwitch(ctx context.Context, deps resource.Dependencies, conf resource.Config, logger logging.Logger) (toggleswitch.Switch, error) {
+	newConf, err := resource.NativeConfig[*switchConfig](conf)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &modbusSwitch{
+		Named:     conf.ResourceName().AsNamed(),
+		logger:    logger,
+		write:     newConf.Write,
+		read:      newConf.Read,
+		positions: newConf.Positions,
+	}
+	s.unitID, err = unitIDOrDefault(newConf.UnitID)
+	if err != nil {
+		return nil, err
+	}
+	if s.read == nil {
+		// Read back the written coil or register
+		s.read = s.write
+	}
+	if len(s.positions) == 0 {
+		s.positions = defaultCoilPositions
+	}
+
+	client, err := GlobalClientRegistry.Get(newConf.ModbusClient)
+	if err != nil {
+		return nil, err
+	}
+	s.mc = client
+	return s, nil
+}
+
+// Writes the value of the position to the coil or register
+func (s *modbusSwitch) SetPosition(ctx context.Context, position uint32, extra map[string]interface{}) error {
+	if int(position) >= len(s.positions) {
+		return fmt.Errorf("position %d out of range, switch has %d positions", position, len(s.positions))
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.write.writeRaw(s.mc, s.unitID, float64(s.positions[position].Value))
+}
+
+// Returns the position whose value matches the value read back from the device
+func (s *modbusSwitch) GetPosition(ctx context.Context, extra map[string]interface{}) (uint32, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, err := s.read.readRaw(s.mc, s.unitID)
+	if err != nil {
+		return 0, err
+	}
+	for i, p := range s.positions {
+		if float64(p.Value) == value {
+			return uint32(i), nil
+		}
+	}
+	return 0, fmt.Errorf("value %v read from %v %d does not match any position", value, s.read.Type, s.read.Offset)
+}
+
+func (s *modbusSwitch) GetNumberOfPositions(ctx context.Context, extra map[string]interface{}) (uint32, []string, error) {
+	labels := make([]string, len(s.positions))
+	for i, p := range s.positions {
+		labels[i] = p.Label
+	}
+	return uint32(len(s.positions)), labels, nil
+}
+
+func (s *modbusSwitch) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	return nil, fmt.Errorf("DoCommand not implemented")
+}
+
+func (s *modbusSwitch) Close(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,458 @@
+package viammodbus
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goburrow/serial"
+	"github.com/simonvetter/modbus"
+	"go.viam.com/rdk/logging"
+)
+
+// Default timeouts of the Modbus library, used when timeout_ms is not set
+const (
+	defaultRTUTimeout = 300 * time.Millisecond
+	defaultTCPTimeout = time.Second
+)
+
+// A byte stream to a device with a deadline for reads and writes
+type deviceLink interface {
+	io.ReadWriteCloser
+	SetDeadline(t time.Time) error
+}
+
+// Settings of the transports the module opens itself, taken from the client configuration
+type transportConfig struct {
+	URL      string
+	Speed    uint
+	DataBits uint
+	Parity   uint
+	StopBits uint
+	Timeout  time.Duration
+	RS485    serial.RS485Config
+
+	// The serial adapter echoes the frames it sends
+	EchoSuppression bool
+}
+
+// Url schemes of the client, those of the Modbus library and of the module's transports
+var clientSchemes = []string{"tcp", "tcp+tls", "udp", "rtu", "rtuovertcp", "rtuoverudp", asciiScheme, simScheme, replayScheme}
+
+// Checks the parts of the url its scheme needs
+func validateURL(u *url.URL) error {
+	switch u.Scheme {
+	case "tcp", "tcp+tls", "udp", "rtuovertcp", "rtuoverudp":
+		host, port, err := net.SplitHostPort(u.Host)
+		if err == nil && host != "" && u.Path == "" && u.RawQuery == "" {
+			_, err = strconv.ParseUint(port, 10, 16)
+		}
+		if err != nil || host == "" || u.Path != "" || u.RawQuery != "" {
+			return fmt.Errorf("%v url requires a host and port, e.g. %v://192.168.1.10:502, got %q", u.Scheme, u.Scheme, u.String())
+		}
+	case "rtu", asciiScheme:
+		if serialDevice(u) == "" {
+			return fmt.Errorf("%v url requires a serial device, e.g. %v:///dev/ttyUSB0", u.Scheme, u.Scheme)
+		}
+	case simScheme, replayScheme:
+		// Checked together with their configuration
+	default:
+		return fmt.Errorf("url scheme %q is not supported, must be one of %v", u.Scheme, strings.Join(clientSchemes, ", "))
+	}
+	return nil
+}
+
+// Returns the device of a serial url, everything after the scheme like the Modbus library
+func serialDevice(u *url.URL) string {
+	return u.Host + u.Path
+}
+
+// Returns true for the schemes without a connection. Re-opening their socket does not help
+// when a device does not answer.
+func isDatagramURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	return err == nil && (u.Scheme == "udp" || u.Scheme == "rtuoverudp")
+}
+
+// Returns the timeout of a device transport, the default of the Modbus library if not set
+func deviceTimeout(rawURL string, timeout time.Duration) time.Duration {
+	if timeout > 0 {
+		return timeout
+	}
+	u, err := url.Parse(rawURL)
+	if err == nil && u.Scheme == "rtu" {
+		return defaultRTUTimeout
+	}
+	if err == nil && u.Scheme == asciiScheme {
+		return defaultASCIITimeout
+	}
+	return defaultTCPTimeout
+}
+
+// Opens a transport to the device of the url. The request and response PDUs are the same
+// for every scheme, only the framing around them differs.
+func newDeviceTransport(cfg transportConfig, logger logging.Logger) (pduTransport, error) {
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	timeout := deviceTimeout(cfg.URL, cfg.Timeout)
+	link := linkHolder{logger: logger}
+	switch u.Scheme {
+	case "tcp", "udp":
+		link.dial = netDialer(u.Scheme, u.Host, timeout)
+	case "rtuovertcp", "rtuoverudp":
+		link.dial = netDialer(strings.TrimPrefix(u.Scheme, "rtuover"), u.Host, timeout)
+	case "rtu":
+		link.dial = serialDialer(serialDevice(u), cfg)
+	case asciiScheme:
+		// ASCII devices commonly use 7 data bits, every character is a hex digit
+		if cfg.DataBits == 0 {
+			cfg.DataBits = 7
+		}
+		link.dial = serialDialer(serialDevice(u), cfg)
+	default:
+		return nil, fmt.Errorf("url scheme %q is not supported by the module transports", u.Scheme)
+	}
+	// Fail like the Modbus library client when the device cannot be reached
+	if err := link.open(); err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "tcp", "udp":
+		return &mbapTransport{linkHolder: link, timeout: timeout}, nil
+	case asciiScheme:
+		return &asciiTransport{linkHolder: link, timeout: timeout, echo: cfg.EchoSuppression}, nil
+	}
+	return &rtuTransport{
+		linkHolder: link,
+		timeout:    timeout,
+		frameDelay: rtuFrameDelay(cfg.Speed),
+		echo:       cfg.EchoSuppression,
+	}, nil
+}
+
+func netDialer(network, address string, timeout time.Duration) func() (deviceLink, error) {
+	return func() (deviceLink, error) {
+		conn, err := net.DialTimeout(network, address, timeout)
+		if err != nil {
+			return nil, err
+		}
+		if network == "udp" {
+			return &datagramLink{Conn: conn}, nil
+		}
+		return conn, nil
+	}
+}
+
+// Serves the reads of a stream from whole datagrams, a datagram socket drops whatever a read
+// does not consume
+type datagramLink struct {
+	net.Conn
+	buf []byte
+}
+
+func (l *datagramLink) Read(p []byte) (int, error) {
+	if len(l.buf) == 0 {
+		buf := make([]byte, 1024)
+		n, err := l.Conn.Read(buf)
+		if err != nil {
+			return 0, err
+		}
+		l.buf = buf[:n]
+	}
+	n := copy(p, l.buf)
+	l.buf = l.buf[n:]
+	return n, nil
+}
+
+func serialDialer(device string, cfg transportConfig) func() (deviceLink, error) {
+	return func() (deviceLink, error) {
+		speed := cfg.Speed
+		if speed == 0 {
+			speed = 19200
+		}
+		dataBits := cfg.DataBits
+		if dataBits == 0 {
+			dataBits = 8
+		}
+		parity := "N"
+		switch cfg.Parity {
+		case modbus.PARITY_EVEN:
+			parity = "E"
+		case modbus.PARITY_ODD:
+			parity = "O"
+		}
+		stopBits := cfg.StopBits
+		if stopBits == 0 {
+			// Keep 11 bits per character as the specification requires
+			stopBits = 1
+			if parity == "N" {
+				stopBits = 2
+			}
+		}
+		port, err := serial.Open(&serial.Config{
+			Address:  device,
+			BaudRate: int(speed),
+			DataBits: int(dataBits),
+			Parity:   parity,
+			StopBits: int(stopBits),
+			Timeout:  10 * time.Millisecond,
+			RS485:    cfg.RS485,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &serialLink{port: port}, nil
+	}
+}
+
+// Gives a serial port the deadline of a network connection. The port itself times out every
+// few milliseconds so reads can give up once the deadline passes.
+type serialLink struct {
+	port     serial.Port
+	deadline time.Time
+}
+
+func (l *serialLink) Read(p []byte) (int, error) {
+	for {
+		n, err := l.port.Read(p)
+		if n > 0 || (err != nil && !errors.Is(err, serial.ErrTimeout)) {
+			return n, err
+		}
+		if !l.deadline.IsZero() && time.Now().After(l.deadline) {
+			return 0, os.ErrDeadlineExceeded
+		}
+	}
+}
+
+func (l *serialLink) Write(p []byte) (int, error) {
+	return l.port.Write(p)
+}
+
+func (l *serialLink) SetDeadline(t time.Time) error {
+	l.deadline = t
+	return nil
+}
+
+func (l *serialLink) Close() error {
+	return l.port.Close()
+}
+
+// Holds the link to the device, opening it again on the next request after an I/O error
+type linkHolder struct {
+	logger logging.Logger
+	dial   func() (deviceLink, error)
+	link   deviceLink
+}
+
+func (h *linkHolder) open() error {
+	link, err := h.dial()
+	if err != nil {
+		return err
+	}
+	h.link = link
+	return nil
+}
+
+func (h *linkHolder) get() (deviceLink, error) {
+	if h.link == nil {
+		if err := h.open(); err != nil {
+			return nil, err
+		}
+	}
+	return h.link, nil
+}
+
+// Drops the link after an error that left it in an unknown state
+func (h *linkHolder) fail(err error) error {
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return modbus.ErrRequestTimedOut
+	}
+	h.logger.Debugf("Closing device link after error: %v", err)
+	h.Close()
+	return err
+}
+
+func (h *linkHolder) Close() error {
+	if h.link == nil {
+		return nil
+	}
+	err := h.link.Close()
+	h.link = nil
+	return err
+}
+
+// Modbus TCP framing over tcp and udp
+type mbapTransport struct {
+	linkHolder
+	timeout time.Duration
+	txnID   uint16
+}
+
+func (t *mbapTransport) exchange(unitID uint8, req []byte) ([]byte, error) {
+	link, err := t.get()
+	if err != nil {
+		return nil, err
+	}
+	t.txnID++
+	frame := make([]byte, 7, 7+len(req))
+	binary.BigEndian.PutUint16(frame[0:2], t.txnID)
+	binary.BigEndian.PutUint16(frame[4:6], uint16(len(req)+1))
+	frame[6] = unitID
+	link.SetDeadline(time.Now().Add(t.timeout))
+	if _, err := link.Write(append(frame, req...)); err != nil {
+		return nil, t.fail(err)
+	}
+
+	header := make([]byte, 7)
+	for {
+		if _, err := io.ReadFull(link, header); err != nil {
+			return nil, t.fail(err)
+		}
+		length := binary.BigEndian.Uint16(header[4:6])
+		if length < 2 || length > 254 || binary.BigEndian.Uint16(header[2:4]) != 0 {
+			return nil, t.fail(modbus.ErrProtocolError)
+		}
+		res := make([]byte, length-1)
+		if _, err := io.ReadFull(link, res); err != nil {
+			return nil, t.fail(err)
+		}
+		// Skip late responses to requests that already timed out
+		if binary.BigEndian.Uint16(header[0:2]) == t.txnID {
+			return res, nil
+		}
+	}
+}
+
+// Modbus RTU framing over a serial line, tcp or udp: unit id, PDU and a CRC
+type rtuTransport struct {
+	linkHolder
+	timeout    time.Duration
+	frameDelay time.Duration
+	last       time.Time
+	echo       bool
+}
+
+// Returns the silence of 3.5 characters that separates frames, fixed above 19200 bauds
+func rtuFrameDelay(speed uint) time.Duration {
+	if speed == 0 {
+		speed = 19200
+	}
+	if speed > 19200 {
+		return 1750 * time.Microsecond
+	}
+	return time.Duration(38500000/speed) * time.Microsecond
+}
+
+func (t *rtuTransport) exchange(unitID uint8, req []byte) ([]byte, error) {
+	link, err := t.get()
+	if err != nil {
+		return nil, err
+	}
+	if wait := t.frameDelay - time.Since(t.last); wait > 0 {
+		time.Sleep(wait)
+	}
+	defer func() { t.last = time.Now() }()
+
+	frame := append([]byte{unitID}, req...)
+	frame = binary.LittleEndian.AppendUint16(frame, crc16(frame))
+	link.SetDeadline(time.Now().Add(t.timeout))
+	if _, err := link.Write(frame); err != nil {
+		return nil, t.fail(err)
+	}
+	if t.echo {
+		if err := t.readEcho(link, frame); err != nil {
+			return nil, err
+		}
+	}
+	if unitID == 0 {
+		// Nobody answers a broadcast
+		return nil, errNoResponse
+	}
+
+	res, err := readRTUFrame(link)
+	if err != nil {
+		t.discard(link)
+		return nil, t.fail(err)
+	}
+	if res[0] != unitID {
+		t.discard(link)
+		return nil, modbus.ErrBadUnitId
+	}
+	return res[1 : len(res)-2], nil
+}
+
+// Reads back the request a two-wire RS-485 adapter echoes before the response arrives
+func (t *rtuTransport) readEcho(link deviceLink, frame []byte) error {
+	echo := make([]byte, len(frame))
+	if _, err := io.ReadFull(link, echo); err != nil {
+		t.discard(link)
+		return t.fail(err)
+	}
+	if !bytes.Equal(echo, frame) {
+		t.discard(link)
+		return fmt.Errorf("%w: echo %x does not match the request %x", modbus.ErrProtocolError, echo, frame)
+	}
+	return nil
+}
+
+// Reads a response frame, its length follows from the function code
+func readRTUFrame(r io.Reader) ([]byte, error) {
+	frame := make([]byte, 3, 256)
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, err
+	}
+	var remaining int
+	switch fc := frame[1]; {
+	case fc&0x80 != 0:
+		remaining = 2
+	case fc == 0x01 || fc == 0x02 || fc == 0x03 || fc == 0x04 || fc == 0x17:
+		remaining = int(frame[2]) + 2
+	case fc == 0x05 || fc == 0x06 || fc == 0x0F || fc == 0x10:
+		remaining = 5
+	case fc == 0x16:
+		remaining = 7
+	default:
+		return nil, fmt.Errorf("%w: unsupported function code %d", modbus.ErrProtocolError, fc)
+	}
+	frame = frame[:3+remaining]
+	if _, err := io.ReadFull(r, frame[3:]); err != nil {
+		return nil, err
+	}
+	n := len(frame)
+	if crc16(frame[:n-2]) != binary.LittleEndian.Uint16(frame[n-2:]) {
+		return nil, modbus.ErrBadCRC
+	}
+	return frame, nil
+}
+
+// Throws away the rest of a broken frame so it does not end up in the next response
+func (t *rtuTransport) discard(link deviceLink) {
+	link.SetDeadline(time.Now().Add(t.frameDelay + 10*time.Millisecond))
+	io.Copy(io.Discard, link)
+}
+
+// CRC-16/MODBUS of an RTU frame
+func crc16(data []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,115 @@
+//go:build linux
+
+package viammodbus
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/simonvetter/modbus"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/test"
+	"golang.org/x/sys/unix"
+)
+
+// Opens a pseudo terminal and returns its master and the path of its slave, the serial
+// device the transport opens
+func openPTY(t *testing.T) (*os.File, string) {
+	t.Helper()
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY, 0)
+	test.That(t, err, test.ShouldBeNil)
+	t.Cleanup(func() { master.Close() })
+	fd := int(master.Fd())
+	test.That(t, unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0), test.ShouldBeNil)
+	n, err := unix.IoctlGetInt(fd, unix.TIOCGPTN)
+	test.That(t, err, test.ShouldBeNil)
+	return master, fmt.Sprintf("/dev/pts/%d", n)
+}
+
+func asciiFrame(b ...byte) []byte {
+	return []byte(":" + strings.ToUpper(hex.EncodeToString(append(b, lrc(b)))) + "\r\n")
+}
+
+// Request of a read of one holding register at address 0 and the answer of the device
+var (
+	testRequest  = []byte{0x03, 0x00, 0x00, 0x00, 0x01}
+	testResponse = []byte{0x03, 0x02, 0x00, 0x2A}
+)
+
+func TestEchoSuppression(t *testing.T) {
+	// Reads a request from the device side of the line
+	readRTU := func(r *bufio.Reader) ([]byte, error) {
+		req := make([]byte, len(testRequest)+3)
+		_, err := io.ReadFull(r, req)
+		return req, err
+	}
+	readASCII := func(r *bufio.Reader) ([]byte, error) {
+		return r.ReadBytes('\n')
+	}
+
+	echoRequest := func(req []byte) []byte { return req }
+	// A valid frame of another request, as if the line had picked up a different master
+	echoOther := func(frame func(...byte) []byte) func([]byte) []byte {
+		return func([]byte) []byte { return frame(0x01, 0x03, 0x00, 0x00, 0x00, 0x02) }
+	}
+
+	for _, tc := range []struct {
+		name     string
+		scheme   string
+		read     func(*bufio.Reader) ([]byte, error)
+		frame    func(...byte) []byte
+		echo     func(req []byte) []byte
+		expected error
+	}{
+		{"rtu echo", "rtu", readRTU, rtuFrame, echoRequest, nil},
+		{"ascii echo", asciiScheme, readASCII, asciiFrame, echoRequest, nil},
+		{"rtu echo mismatch", "rtu", readRTU, rtuFrame, echoOther(rtuFrame), modbus.ErrProtocolError},
+		{"ascii echo mismatch", asciiScheme, readASCII, asciiFrame, echoOther(asciiFrame), modbus.ErrProtocolError},
+		{"rtu no echo", "rtu", readRTU, rtuFrame, nil, modbus.ErrRequestTimedOut},
+		{"ascii no echo", asciiScheme, readASCII, asciiFrame, nil, modbus.ErrRequestTimedOut},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			master, device := openPTY(t)
+			transport, err := newDeviceTransport(transportConfig{
+				URL:             tc.scheme + "://" + device,
+				Speed:           19200,
+				Timeout:         200 * time.Millisecond,
+				EchoSuppression: true,
+			}, logging.NewTestLogger(t))
+			test.That(t, err, test.ShouldBeNil)
+			defer transport.Close()
+
+			// The far end of the line echoes the request, then the device answers
+			received := make(chan []byte, 1)
+			go func() {
+				req, err := tc.read(bufio.NewReader(master))
+				if err != nil {
+					close(received)
+					return
+				}
+				received <- req
+				if tc.echo == nil {
+					return
+				}
+				master.Write(tc.echo(req))
+				master.Write(tc.frame(append([]byte{0x01}, testResponse...)...))
+			}()
+
+			res, err := transport.exchange(0x01, testRequest)
+			test.That(t, <-received, test.ShouldResemble, tc.frame(append([]byte{0x01}, testRequest...)...))
+			if tc.expected != nil {
+				test.That(t, errors.Is(err, tc.expected), test.ShouldBeTrue)
+				return
+			}
+			test.That(t, err, test.ShouldBeNil)
+			test.That(t, res, test.ShouldResemble, testResponse)
+		})
+	}
+}
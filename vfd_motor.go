@@ -0,0 +1,338 @@
+package viammodbus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+
+	"go.viam.com/rdk/components/motor"
+	"go.viam.com/rdk/logging"
+	"go.viam.com/rdk/resource"
+)
+
+var VFDMotorModel = NamespaceFamily.WithModel("vfd-motor")
+
+func init() {
+	resource.RegisterComponent(
+		motor.API,
+		VFDMotorModel,
+		resource.Registration[motor.Motor, *vfdMotorConfig]{
+			Constructor: newVFDMotor,
+		})
+}
+
+type vfdMotorConfig struct {
+	ModbusClient string `json:"modbus_connection_name"`
+	UnitID       int    `json:"unit_id"`
+
+	// Control word bits written to start, stop and reverse the drive
+	ControlWord     int  `json:"control_word"`
+	ControlWordBase int  `json:"control_word_base"`
+	RunBit          int  `json:"run_bit"`
+	ReverseBit      *int `json:"reverse_bit"`
+	FaultResetBit   *int `json:"fault_reset_bit"`
+
+	// Optional status word bits read back from the drive
+	StatusWord *pointConfig `json:"status_word"`
+	RunningBit *int         `json:"running_bit"`
+	FaultBit   *int         `json:"fault_bit"`
+
+	FrequencySetpoint *pointConfig `json:"frequency_setpoint"`
+	ActualSpeed       *pointConfig `json:"actual_speed"`
+	MaxFrequency      float64      `json:"max_frequency_hz"`
+	MaxRPM            float64      `json:"max_rpm"`
+}
+
+func (cfg *vfdMotorConfig) Validate(path string) ([]string, []string, error) {
+	if cfg.ModbusClient == "" {
+		return nil, nil, resource.NewConfigValidationFieldRequiredError(path, "modbus_connection_name")
+	}
+	if _, err := unitIDOrDefault(cfg.UnitID); err != nil {
+		return nil, nil, err
+	}
+	if cfg.ControlWord < 0 || cfg.ControlWord > 65535 {
+		return nil, nil, fmt.Errorf("control_word must be between 0 and 65535, got %d", cfg.ControlWord)
+	}
+	if cfg.ControlWordBase < 0 || cfg.ControlWordBase > 0xFFFF {
+		return nil, nil, fmt.Errorf("control_word_base must be between 0 and 65535, got %d", cfg.ControlWordBase)
+	}
+	bits := map[string]*int{"run_bit": &cfg.RunBit, "reverse_bit": cfg.ReverseBit, "fault_reset_bit": cfg.FaultResetBit,
+		"running_bit": cfg.RunningBit, "fault_bit": cfg.FaultBit}
+	for name, bit := range bits {
+		if bit != nil && (*bit < 0 || *bit > 15) {
+			return nil, nil, fmt.Errorf("%s must be between 0 and 15, got %d", name, *bit)
+		}
+	}
+	if cfg.StatusWord != nil {
+		if err := cfg.StatusWord.validate("status_word", false); err != nil {
+			return nil, nil, err
+		}
+		if cfg.StatusWord.isBit() || cfg.StatusWord.width() != 1 {
+			return nil, nil, errors.New("status_word must be a 16 bit register")
+		}
+	} else if cfg.RunningBit != nil || cfg.FaultBit != nil {
+		return nil, nil, errors.New("status_word is required for running_bit and fault_bit")
+	}
+	if cfg.FrequencySetpoint == nil {
+		return nil, nil, resource.NewConfigValidationFieldRequiredError(path, "frequency_setpoint")
+	}
+	if err := cfg.FrequencySetpoint.validate("frequency_setpoint", true); err != nil {
+		return nil, nil, err
+	}
+	if cfg.FrequencySetpoint.isBit() {
+		return nil, nil, errors.New("frequency_setpoint must be a holding register")
+	}
+	if cfg.ActualSpeed != nil {
+		if err := cfg.ActualSpeed.validate("actual_speed", false); err != nil {
+			return nil, nil, err
+		}
+	}
+	if cfg.MaxFrequency <= 0 {
+		return nil, nil, fmt.Errorf("max_frequency_hz must be positive, got %v", cfg.MaxFrequency)
+	}
+	if cfg.MaxRPM < 0 {
+		return nil, nil, fmt.Errorf("max_rpm must be non-negative, got %v", cfg.MaxRPM)
+	}
+	return []string{cfg.ModbusClient}, nil, nil
+}
+
+type vfdMotor struct {
+	resource.AlwaysRebuild
+	resource.Named
+	mu     sync.Mutex
+	logger logging.Logger
+	mc     *modbusClient
+	unitID uint8
+	cfg    *vfdMotorConfig
+
+	// Last commanded power between -1 and 1
+	powerPct float64
+}
+
+func newVFDMotor(ctx context.Context, deps resource.Dependencies, conf resource.Config, logger logging.Logger) (motor.Motor, error) {
+	newConf, err := resource.NativeConfig[*vfdMotorConfig](conf)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &vfdMotor{
+		Named:  conf.ResourceName().AsNamed(),
+		logger: logger,
+		cfg:    newConf,
+	}
+	m.unitID, err = unitIDOrDefault(newConf.UnitID)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := GlobalClientRegistry.Get(newConf.ModbusClient)
+	if err != nil {
+		return nil, err
+	}
+	m.mc = client
+	return m, nil
+}
+
+// Runs the drive at the given fraction of the maximum frequency, negative values reverse
+func (m *vfdMotor) SetPower(ctx context.Context, powerPct float64, extra map[string]interface{}) error {
+	if math.Abs(powerPct) > 1 {
+		return fmt.Errorf("power must be between -1 and 1, got %v", powerPct)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.run(powerPct)
+}
+
+// Runs the drive at the frequency matching the rpm, requires max_rpm
+func (m *vfdMotor) SetRPM(ctx context.Context, rpm float64, extra map[string]interface{}) error {
+	if m.cfg.MaxRPM == 0 {
+		return errors.New("max_rpm is required to set the rpm")
+	}
+	if math.Abs(rpm) > m.cfg.MaxRPM {
+		return fmt.Errorf("rpm %v exceeds max_rpm %v", rpm, m.cfg.MaxRPM)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.run(rpm / m.cfg.MaxRPM)
+}
+
+func (m *vfdMotor) run(powerPct float64) error {
+	if powerPct == 0 {
+		return m.stop()
+	}
+	if powerPct < 0 && m.cfg.ReverseBit == nil {
+		return errors.New("reverse_bit is required to run in reverse")
+	}
+	if err := m.checkFault(); err != nil {
+		return err
+	}
+
+	frequency := math.Abs(powerPct) * m.cfg.MaxFrequency
+	if err := m.cfg.FrequencySetpoint.write(m.mc, m.unitID, frequency); err != nil {
+		return err
+	}
+	word := setBit(uint16(m.cfg.ControlWordBase), m.cfg.RunBit)
+	if powerPct < 0 {
+		word = setBit(word, *m.cfg.ReverseBit)
+	}
+	if err := m.mc.WriteUInt16(uint16(m.cfg.ControlWord), word, m.unitID); err != nil {
+		return err
+	}
+	m.powerPct = powerPct
+	return nil
+}
+
+func (m *vfdMotor) Stop(ctx context.Context, extra map[string]interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stop()
+}
+
+func (m *vfdMotor) stop() error {
+	if err := m.mc.WriteUInt16(uint16(m.cfg.ControlWord), uint16(m.cfg.ControlWordBase), m.unitID); err != nil {
+		return err
+	}
+	m.powerPct = 0
+	return nil
+}
+
+// Returns an error if the drive reports a fault
+func (m *vfdMotor) checkFault() error {
+	if m.cfg.FaultBit == nil {
+		return nil
+	}
+	status, err := m.readStatus()
+	if err != nil {
+		return err
+	}
+	if hasBit(status, *m.cfg.FaultBit) {
+		return errors.New("drive reports a fault, reset it with DoCommand {\"reset_fault\": true}")
+	}
+	return nil
+}
+
+func (m *vfdMotor) readStatus() (uint16, error) {
+	return m.mc.ReadUInt16(uint16(m.cfg.StatusWord.Offset), m.cfg.StatusWord.regType(), m.unitID)
+}
+
+func (m *vfdMotor) IsPowered(ctx context.Context, extra map[string]interface{}) (bool, float64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cfg.RunningBit != nil {
+		status, err := m.readStatus()
+		if err != nil {
+			return false, 0, err
+		}
+		if !hasBit(status, *m.cfg.RunningBit) {
+			return false, 0, nil
+		}
+	}
+	return m.powerPct != 0, math.Abs(m.powerPct), nil
+}
+
+func (m *vfdMotor) IsMoving(ctx context.Context) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cfg.ActualSpeed != nil {
+		speed, err := m.cfg.ActualSpeed.read(m.mc, m.unitID)
+		if err != nil {
+			return false, err
+		}
+		return speed != 0, nil
+	}
+	if m.cfg.RunningBit != nil {
+		status, err := m.readStatus()
+		if err != nil {
+			return false, err
+		}
+		return hasBit(status, *m.cfg.RunningBit), nil
+	}
+	return m.powerPct != 0, nil
+}
+
+func (m *vfdMotor) GoFor(ctx context.Context, rpm, revolutions float64, extra map[string]interface{}) error {
+	return errors.New("GoFor is not supported by the vfd motor, use SetRPM")
+}
+
+func (m *vfdMotor) GoTo(ctx context.Context, rpm, positionRevolutions float64, extra map[string]interface{}) error {
+	return errors.New("GoTo is not supported by the vfd motor")
+}
+
+func (m *vfdMotor) ResetZeroPosition(ctx context.Context, offset float64, extra map[string]interface{}) error {
+	return errors.New("ResetZeroPosition is not supported by the vfd motor")
+}
+
+func (m *vfdMotor) Position(ctx context.Context, extra map[string]interface{}) (float64, error) {
+	return 0, errors.New("Position is not supported by the vfd motor")
+}
+
+func (m *vfdMotor) Properties(ctx context.Context, extra map[string]interface{}) (motor.Properties, error) {
+	return motor.Properties{PositionReporting: false}, nil
+}
+
+// DoCommand supports {"status": true} to read the drive state and {"reset_fault": true}
+// to pulse the fault reset bit
+func (m *vfdMotor) DoCommand(ctx context.Context, cmd map[string]interface{}) (map[string]interface{}, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, got := cmd["reset_fault"]; got {
+		if m.cfg.FaultResetBit == nil {
+			return nil, errors.New("fault_reset_bit is not configured")
+		}
+		word := setBit(uint16(m.cfg.ControlWordBase), *m.cfg.FaultResetBit)
+		if err := m.mc.WriteUInt16(uint16(m.cfg.ControlWord), word, m.unitID); err != nil {
+			return nil, err
+		}
+		if err := m.stop(); err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"success": true}, nil
+	}
+
+	if _, got := cmd["status"]; got {
+		result := map[string]interface{}{"power_pct": m.powerPct}
+		if m.cfg.StatusWord != nil {
+			status, err := m.readStatus()
+			if err != nil {
+				return nil, err
+			}
+			result["status_word"] = int(status)
+			if m.cfg.RunningBit != nil {
+				result["running"] = hasBit(status, *m.cfg.RunningBit)
+			}
+			if m.cfg.FaultBit != nil {
+				result["fault"] = hasBit(status, *m.cfg.FaultBit)
+			}
+		}
+		if m.cfg.ActualSpeed != nil {
+			speed, err := m.cfg.ActualSpeed.read(m.mc, m.unitID)
+			if err != nil {
+				return nil, err
+			}
+			result["actual_speed"] = speed
+		}
+		return result, nil
+	}
+
+	return nil, fmt.Errorf("DoCommand not implemented")
+}
+
+func (m *vfdMotor) Close(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.powerPct != 0 {
+		return m.stop()
+	}
+	return nil
+}
+
+func setBit(word uint16, bit int) uint16 {
+	return word | 1<<bit
+}
+
+func hasBit(word uint16, bit int) bool {
+	return word&(1<<bit) != 0
+}